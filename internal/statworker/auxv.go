@@ -0,0 +1,73 @@
+package statworker
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// atClkTck is the auxiliary vector entry holding the kernel's USER_HZ,
+// the unit of the counters in /proc/stat. See getauxval(3).
+const atClkTck = 17
+
+// defaultUserHZ is used when the auxiliary vector cannot be read.
+// https://github.com/prometheus/procfs/blob/c0c2a8be4d30a2e2cb95ea371a6f32a506d3e45e/proc_stat.go#L40
+const defaultUserHZ = 100
+
+var (
+	userHZ     uint64
+	userHZOnce sync.Once
+)
+
+// clockTicks returns USER_HZ read from /proc/self/auxv.
+func clockTicks() uint64 {
+	userHZOnce.Do(func() {
+		userHZ = defaultUserHZ
+		f, err := os.Open("/proc/self/auxv")
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		hz, err := readClockTicks(f)
+		if err != nil || hz == 0 {
+			return
+		}
+		userHZ = hz
+	})
+	return userHZ
+}
+
+// readClockTicks looks up AT_CLKTCK in an auxiliary vector, which is a list
+// of native word sized (type, value) pairs terminated by AT_NULL.
+func readClockTicks(r io.Reader) (uint64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	word := strconv.IntSize / 8
+	for i := 0; i+2*word <= len(b); i += 2 * word {
+		var k, v uint64
+		if word == 8 {
+			k = binary.NativeEndian.Uint64(b[i:])
+			v = binary.NativeEndian.Uint64(b[i+word:])
+		} else {
+			k = uint64(binary.NativeEndian.Uint32(b[i:]))
+			v = uint64(binary.NativeEndian.Uint32(b[i+word:]))
+		}
+		if k == 0 {
+			break
+		}
+		if k == atClkTck {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("AT_CLKTCK not found in auxv")
+}
+
+// jiffiesToSeconds converts a /proc/stat counter to seconds.
+func jiffiesToSeconds(j uint64) float64 {
+	return float64(j) / float64(clockTicks())
+}
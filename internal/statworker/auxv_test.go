@@ -0,0 +1,58 @@
+package statworker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func auxvBytes(pairs ...uint64) []byte {
+	var buf bytes.Buffer
+	for _, p := range pairs {
+		if strconv.IntSize == 64 {
+			binary.Write(&buf, binary.NativeEndian, p)
+		} else {
+			binary.Write(&buf, binary.NativeEndian, uint32(p))
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestReadClockTicks(t *testing.T) {
+	b := auxvBytes(33, 0x7fff0000, 6, 4096, atClkTck, 250, 0, 0)
+	hz, err := readClockTicks(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("readClockTicks() error = %v", err)
+	}
+	if hz != 250 {
+		t.Errorf("expected 250, got %d", hz)
+	}
+}
+
+func TestReadClockTicks_StopsAtNull(t *testing.T) {
+	b := auxvBytes(6, 4096, 0, 0, atClkTck, 250)
+	if _, err := readClockTicks(bytes.NewReader(b)); err == nil {
+		t.Error("expected error for AT_CLKTCK after AT_NULL")
+	}
+}
+
+func TestReadClockTicks_Truncated(t *testing.T) {
+	b := auxvBytes(6, 4096, atClkTck, 250)
+	if _, err := readClockTicks(bytes.NewReader(b[:len(b)-1])); err == nil {
+		t.Error("expected error for truncated auxv")
+	}
+}
+
+func TestClockTicks(t *testing.T) {
+	if _, err := os.Stat("/proc/self/auxv"); err != nil {
+		t.Skip("no /proc/self/auxv")
+	}
+	if hz := clockTicks(); hz == 0 {
+		t.Error("expected non-zero USER_HZ")
+	}
+	if s := jiffiesToSeconds(clockTicks() * 3); s != 3 {
+		t.Errorf("expected 3 seconds, got %v", s)
+	}
+}
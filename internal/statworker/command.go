@@ -1,10 +1,13 @@
 package statworker
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"math"
+	"slices"
 	"sort"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -23,72 +26,241 @@ func (*Worker) Hello(_ context.Context, _ *connect.Request[emptypb.Empty]) (*con
 	return connect.NewResponse(&maxcpu.HelloResponse{Message: "OK"}), nil
 }
 
-func (w *Worker) GetStats(_ context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[maxcpu.StatsResponse], error) {
-	stats, err := w.stats()
+func (w *Worker) GetStats(_ context.Context, req *connect.Request[maxcpu.StatsRequest]) (*connect.Response[maxcpu.StatsResponse], error) {
+	res, err := w.stats(req.Msg)
 	if err != nil {
 		return nil, err
 	}
-	return connect.NewResponse(&maxcpu.StatsResponse{Metrics: stats}), nil
+	return connect.NewResponse(res), nil
 }
 
-func (w *Worker) stats() ([]*maxcpu.Metric, error) {
+func (w *Worker) GetSamples(_ context.Context, req *connect.Request[maxcpu.SamplesRequest]) (*connect.Response[maxcpu.SamplesResponse], error) {
+	return connect.NewResponse(&maxcpu.SamplesResponse{Samples: w.samples(req.Msg.From, req.Msg.To)}), nil
+}
+
+// samples returns the samples retained taken between from and to in unix
+// time, in the order they were taken. Zero means unbounded.
+func (w *Worker) samples(from, to int64) []*maxcpu.Sample {
 	// reset idle time
 	atomic.StoreInt64(&w.idleTime, 0)
 
 	w.lock.Lock()
 	defer w.lock.Unlock()
 
-	var usages sort.Float64Slice
+	var res []*maxcpu.Sample
+	for _, u := range w.samplesSince(0) {
+		if epoch := u.Time.Unix(); from != 0 && epoch < from || to != 0 && epoch > to {
+			continue
+		}
+		res = append(res, toSample(u))
+	}
+	slices.SortFunc(res, func(a, b *maxcpu.Sample) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	return res
+}
+
+// toSample converts a sample to the message, the gaps in seconds.
+func toSample(u *cpuUsage) *maxcpu.Sample {
+	return &maxcpu.Sample{
+		Seq:       u.Seq,
+		Epoch:     u.Time.Unix(),
+		Usage:     u.Usage,
+		User:      jiffiesToSeconds(u.GapUser),
+		Nice:      jiffiesToSeconds(u.GapNice),
+		System:    jiffiesToSeconds(u.GapSystem),
+		Idle:      jiffiesToSeconds(u.GapIdle),
+		Iowait:    jiffiesToSeconds(u.GapIowait),
+		IRQ:       jiffiesToSeconds(u.GapIRQ),
+		SoftIRQ:   jiffiesToSeconds(u.GapSoftIRQ),
+		Steal:     jiffiesToSeconds(u.GapSteal),
+		Guest:     jiffiesToSeconds(u.GapGuest),
+		GuestNice: jiffiesToSeconds(u.GapGuestNice),
+	}
+}
+
+// usageGroup is the graph name of the aggregated cpu usage
+const usageGroup = "us_sy_wa_si_st_usage"
+
+// stats returns the stats of the samples since the previous read of the
+// consumer. The history is shared by the consumers, a consumer which has
+// not read yet gets all the samples retained. An Ack of this daemon
+// replaces the read position kept for the consumer. With Peek, the read
+// position is kept. With Window, the stats are of the samples in the last
+// Window seconds, one sample a second, regardless of the read position.
+func (w *Worker) stats(req *maxcpu.StatsRequest) (*maxcpu.StatsResponse, error) {
+	window := uint64(req.Window)
+	if req.Window != 0 && (req.Window < 2 || window > historySize-1) {
+		return nil, fmt.Errorf("window must be between 2 and %d seconds", historySize-1)
+	}
+	// reset idle time
+	atomic.StoreInt64(&w.idleTime, 0)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	cursor := w.consumers[req.Consumer]
+	switch {
+	case window != 0:
+		cursor = 0
+		if w.seq > window {
+			cursor = w.seq - window
+		}
+	case req.StartedAt != 0 && req.StartedAt == w.startedAt:
+		cursor = req.Ack
+	}
+	metrics, err := w.statsSince(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if window == 0 && !req.Peek {
+		w.consumers[req.Consumer] = w.seq
+		w.expireConsumers()
+	}
+	res := &maxcpu.StatsResponse{Metrics: metrics, Seq: w.seq, StartedAt: w.startedAt}
+	if w.cfg.Steal {
+		res.StealThreshold = w.cfg.StealThreshold
+	}
+	return res, nil
+}
+
+// statsSince returns the stats of the samples after the sequence number
+// seq. The caller must hold the lock.
+func (w *Worker) statsSince(seq uint64) ([]*maxcpu.Metric, error) {
+	samples := w.samplesSince(seq)
+	if len(samples) < 2 {
+		return nil, fmt.Errorf("calculating now")
+	}
+
+	res := make([]*maxcpu.Metric, 0)
+
+	epoch := time.Now().Unix()
+	res = append(res, w.samplerMetrics(samples, epoch)...)
+	if w.topology != nil {
+		res = append(res, w.topology.metrics(samples, epoch)...)
+	}
+	if w.nodes != nil {
+		res = append(res, numaMetrics(w.nodes, samples, epoch)...)
+	}
+	if w.groups != nil {
+		res = append(res, cpuGroupMetrics(w.groups, samples, epoch)...)
+	}
+	if w.cfg.CoreSkew {
+		res = append(res, skewMetrics(samples, epoch)...)
+	}
+	if w.cfg.Steal {
+		res = append(res, stealMetrics(samples, w.cfg.StealThreshold, epoch)...)
+	}
+
+	return res, nil
+}
+
+// samplesSince returns the samples retained after the sequence number seq.
+// The caller must hold the lock.
+func (w *Worker) samplesSince(seq uint64) []*cpuUsage {
+	var samples []*cpuUsage
 	var i int64
-	var total float64
 	for i = 1; i < historySize; i++ {
-		if w.usages[i] != nil {
-			usages = append(usages, w.usages[i].Usage)
-			total += w.usages[i].Usage
+		if w.usages[i] != nil && w.usages[i].Seq > seq {
+			samples = append(samples, w.usages[i])
 		}
 	}
+	return samples
+}
 
-	// clear stats
-	current := w.usages[w.current]
-	w.current = 0
-	w.usages = make([]*cpuUsage, historySize)
-	w.usages[0] = current
+// expireConsumers forgets the consumers which have not read since the
+// oldest sample retained. They get all the samples at the next read as a
+// new consumer does. The caller must hold the lock.
+func (w *Worker) expireConsumers() {
+	oldest := w.seq
+	var i int64
+	for i = 1; i < historySize; i++ {
+		if w.usages[i] != nil && w.usages[i].Seq < oldest {
+			oldest = w.usages[i].Seq
+		}
+	}
+	for c, seq := range w.consumers {
+		if seq < oldest {
+			delete(w.consumers, c)
+		}
+	}
+}
 
-	res := make([]*maxcpu.Metric, 0)
+// peakSample returns the sample with the highest cpu usage.
+func peakSample(samples []*cpuUsage) *cpuUsage {
+	var peak *cpuUsage
+	for _, u := range samples {
+		if peak == nil || u.Usage > peak.Usage {
+			peak = u
+		}
+	}
+	return peak
+}
 
-	if len(usages) < 2 {
-		return res, fmt.Errorf("calculating now")
+// sortedKeys returns the keys of m in order, for stable output.
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
+	slices.Sort(keys)
+	return keys
+}
 
-	sort.Sort(usages)
-	flen := float64(len(usages))
-	epoch := time.Now().Unix()
+// metricKey replaces the characters not allowed in a metric name with "_".
+func metricKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// summarize returns max, min, avg, 90 and 75 percentile of the values.
+func summarize(group string, values []float64, epoch int64) []*maxcpu.Metric {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := make(sort.Float64Slice, len(values))
+	copy(sorted, values)
+	var total float64
+	for _, v := range sorted {
+		total += v
+	}
+	sort.Sort(sorted)
+	flen := float64(len(sorted))
 
+	res := make([]*maxcpu.Metric, 0, 5)
 	res = append(res, &maxcpu.Metric{
+		Group:  group,
 		Key:    "max",
-		Metric: usages[round(flen)],
+		Metric: sorted[round(flen)],
 		Epoch:  epoch,
 	})
 	res = append(res, &maxcpu.Metric{
+		Group:  group,
 		Key:    "min",
-		Metric: usages[0],
+		Metric: sorted[0],
 		Epoch:  epoch,
 	})
 	res = append(res, &maxcpu.Metric{
+		Group:  group,
 		Key:    "avg",
 		Metric: total / flen,
 		Epoch:  epoch,
 	})
 	res = append(res, &maxcpu.Metric{
+		Group:  group,
 		Key:    "90pt",
-		Metric: usages[round(flen*0.90)],
+		Metric: sorted[round(flen*0.90)],
 		Epoch:  epoch,
 	})
 	res = append(res, &maxcpu.Metric{
+		Group:  group,
 		Key:    "75pt",
-		Metric: usages[round(flen*0.75)],
+		Metric: sorted[round(flen*0.75)],
 		Epoch:  epoch,
 	})
-
-	return res, nil
+	return res
 }
@@ -1,30 +1,41 @@
 package statworker
 
 import (
-	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
 )
 
-func newTestWorkerWithUsages(usages []float64, current int64) *Worker {
-	w := &Worker{
-		usages:  make([]*cpuUsage, historySize),
-		current: current,
-	}
+// newTestWorkerWithUsages stores the samples of the usages in percent. The
+// first one is the baseline.
+func newTestWorkerWithUsages(usages []float64) *Worker {
+	w := newWorker(Config{})
+	var cpu cpuStat
 	for i, u := range usages {
-		if i >= int(historySize) {
-			break
+		if i > 0 {
+			cpu.User += uint64(u)
+			cpu.Idle += uint64(100 - u)
 		}
-		w.usages[i] = &cpuUsage{Usage: u}
+		c := cpu
+		w.calculatingGap(&c)
 	}
-	w.lock = sync.Mutex{}
 	return w
 }
 
+// metricsOf returns the metrics of the stats of the request.
+func metricsOf(w *Worker, req *maxcpu.StatsRequest) ([]*maxcpu.Metric, error) {
+	res, err := w.stats(req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Metrics, nil
+}
+
 func TestMStats_NotEnoughData(t *testing.T) {
-	w := newTestWorkerWithUsages([]float64{10.0}, 0)
-	resp, err := w.stats()
+	w := newTestWorkerWithUsages([]float64{10.0})
+	resp, err := metricsOf(w, &maxcpu.StatsRequest{})
 	if err != nil {
 		// "calculating now" エラーが返ることを期待
 		if err.Error() != "calculating now" {
@@ -38,8 +49,8 @@ func TestMStats_NotEnoughData(t *testing.T) {
 
 func TestMStats_EnoughData(t *testing.T) {
 	usages := []float64{0, 10, 20, 30, 40, 50}
-	w := newTestWorkerWithUsages(usages, 5)
-	resp, err := w.stats()
+	w := newTestWorkerWithUsages(usages)
+	resp, err := metricsOf(w, &maxcpu.StatsRequest{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -49,6 +60,9 @@ func TestMStats_EnoughData(t *testing.T) {
 	keys := map[string]bool{}
 	for _, m := range resp {
 		keys[m.Key] = true
+		if m.Group != usageGroup {
+			t.Errorf("unexpected group: %s", m.Group)
+		}
 		if m.Epoch == 0 {
 			t.Errorf("expected non-zero epoch")
 		}
@@ -61,40 +75,90 @@ func TestMStats_EnoughData(t *testing.T) {
 }
 
 func TestMStats_ResetsIdleTime(t *testing.T) {
-	w := newTestWorkerWithUsages([]float64{0, 10, 20}, 2)
+	w := newTestWorkerWithUsages([]float64{0, 10, 20})
 	atomic.StoreInt64(&w.idleTime, 123)
-	_, _ = w.stats()
+	_, _ = metricsOf(w, &maxcpu.StatsRequest{})
 	if got := atomic.LoadInt64(&w.idleTime); got != 0 {
 		t.Errorf("expected idleTime reset to 0, got %d", got)
 	}
 }
 
-func TestMStats_ClearsStatsExceptCurrent(t *testing.T) {
+func TestMStats_Consumers(t *testing.T) {
 	usages := []float64{0, 10, 20, 30}
-	w := newTestWorkerWithUsages(usages, 2)
-	_, err := w.stats()
+	w := newTestWorkerWithUsages(usages)
+	maxOf := func(resp []*maxcpu.Metric) float64 {
+		for _, m := range resp {
+			if m.Key == "max" {
+				return m.Metric
+			}
+		}
+		return -1
+	}
+	resp, err := metricsOf(w, &maxcpu.StatsRequest{Consumer: "a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := maxOf(resp); got != 30 {
+		t.Errorf("expected max 30, got %v", got)
+	}
+	// the history is kept for the other consumers
+	if w.usages[1] == nil {
+		t.Error("expected usages to be retained")
+	}
+	if _, err := metricsOf(w, &maxcpu.StatsRequest{Consumer: "a"}); err == nil || err.Error() != "calculating now" {
+		t.Errorf("expected calculating now without new samples, got %v", err)
+	}
+
+	w.calculatingGap(&cpuStat{User: 100, Idle: 300})
+	w.calculatingGap(&cpuStat{User: 150, Idle: 350})
+	resp, err = metricsOf(w, &maxcpu.StatsRequest{Consumer: "a"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if w.current != 0 {
-		t.Errorf("expected current to be 0, got %d", w.current)
+	if got := maxOf(resp); got != 50 {
+		t.Errorf("expected max 50 since the previous read, got %v", got)
 	}
-	if w.usages[0] == nil || w.usages[0].Usage != 20 {
-		t.Errorf("expected usages[0] to be the previous current, got %+v", w.usages[0])
+	for _, m := range resp {
+		if m.Key == "min" && m.Metric != 40 {
+			t.Errorf("expected min 40 since the previous read, got %v", m.Metric)
+		}
 	}
-	for i := 1; i < int(historySize); i++ {
-		if w.usages[i] != nil {
-			t.Errorf("expected usages[%d] to be nil, got %+v", i, w.usages[i])
+	// b gets the whole history
+	resp, err = metricsOf(w, &maxcpu.StatsRequest{Consumer: "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range resp {
+		if m.Key == "min" && m.Metric != 10 {
+			t.Errorf("expected min 10 for a new consumer, got %v", m.Metric)
 		}
 	}
+	if w.consumers["a"] != w.seq || w.consumers["b"] != w.seq {
+		t.Errorf("unexpected cursors: %v", w.consumers)
+	}
+}
+
+func TestMStats_ExpireConsumers(t *testing.T) {
+	w := newTestWorkerWithUsages([]float64{0, 10, 20})
+	w.consumers["old"] = 0
+	w.consumers["recent"] = 1
+	if _, err := metricsOf(w, &maxcpu.StatsRequest{Consumer: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.consumers["old"]; ok {
+		t.Error("expected the consumer behind the history to be expired")
+	}
+	if _, ok := w.consumers["recent"]; !ok {
+		t.Error("expected the consumer within the history to be kept")
+	}
 }
 
 func TestMStats_ConcurrentAccess(t *testing.T) {
 	usages := []float64{0, 10, 20, 30, 40, 50}
-	w := newTestWorkerWithUsages(usages, 5)
+	w := newTestWorkerWithUsages(usages)
 	done := make(chan struct{})
 	go func() {
-		_, _ = w.stats()
+		_, _ = metricsOf(w, &maxcpu.StatsRequest{})
 		close(done)
 	}()
 	// Try to acquire the lock to ensure no deadlock
@@ -104,3 +168,135 @@ func TestMStats_ConcurrentAccess(t *testing.T) {
 		t.Fatal("mStats deadlocked")
 	}
 }
+
+func TestMetricKey(t *testing.T) {
+	tests := map[string]string{
+		"eth0":           "eth0",
+		"ACPI:Ged":       "ACPI_Ged",
+		"x86_pkg_temp":   "x86_pkg_temp",
+		"nvme0n1p1.a b/": "nvme0n1p1_a_b_",
+	}
+	for input, want := range tests {
+		if got := metricKey(input); got != want {
+			t.Errorf("metricKey(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestMStats_Peek(t *testing.T) {
+	w := newTestWorkerWithUsages([]float64{0, 10, 20})
+	for range 2 {
+		resp, err := metricsOf(w, &maxcpu.StatsRequest{Peek: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(resp) != 5 {
+			t.Errorf("expected 5 metrics, got %d", len(resp))
+		}
+	}
+	if _, ok := w.consumers[""]; ok {
+		t.Error("expected peek not to register the consumer")
+	}
+	if _, err := metricsOf(w, &maxcpu.StatsRequest{}); err != nil {
+		t.Fatalf("expected the samples to be left after peek: %v", err)
+	}
+	if _, err := metricsOf(w, &maxcpu.StatsRequest{Peek: true}); err == nil {
+		t.Error("expected calculating now after the read")
+	}
+}
+
+func TestMStats_Window(t *testing.T) {
+	w := newTestWorkerWithUsages([]float64{0, 10, 20, 30, 40})
+	got := map[string]float64{}
+	resp, err := metricsOf(w, &maxcpu.StatsRequest{Window: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range resp {
+		got[m.Key] = m.Metric
+	}
+	if got["min"] != 30 || got["max"] != 40 {
+		t.Errorf("expected the last 2 samples, got %v", got)
+	}
+	// longer than the samples
+	resp, err = metricsOf(w, &maxcpu.StatsRequest{Window: 60})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range resp {
+		got[m.Key] = m.Metric
+	}
+	if got["min"] != 10 || got["max"] != 40 {
+		t.Errorf("expected all the samples, got %v", got)
+	}
+	if len(w.consumers) != 0 {
+		t.Errorf("expected no consumers to be registered, got %v", w.consumers)
+	}
+	for _, window := range []int32{-1, 1, historySize} {
+		if _, err := metricsOf(w, &maxcpu.StatsRequest{Window: window}); err == nil {
+			t.Errorf("expected error for window %d", window)
+		}
+	}
+}
+
+func TestMStats_Ack(t *testing.T) {
+	w := newTestWorkerWithUsages([]float64{0, 10, 20, 30})
+	res, err := w.stats(&maxcpu.StatsRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Seq != 3 || res.StartedAt != w.startedAt {
+		t.Errorf("unexpected seq %d and startedAt %d", res.Seq, res.StartedAt)
+	}
+
+	// the response is lost, the retry acks the previous seq
+	if _, err := w.stats(&maxcpu.StatsRequest{}); err == nil {
+		t.Error("expected calculating now without ack")
+	}
+	res, err = w.stats(&maxcpu.StatsRequest{Ack: 1, StartedAt: w.startedAt})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range res.Metrics {
+		if m.Key == "min" && m.Metric != 20 {
+			t.Errorf("expected min 20 after the ack, got %v", m.Metric)
+		}
+	}
+
+	// an ack of another daemon is ignored
+	if _, err := w.stats(&maxcpu.StatsRequest{Ack: 1, StartedAt: w.startedAt + 1}); err == nil {
+		t.Error("expected the ack of another daemon to be ignored")
+	}
+}
+
+func TestSamples(t *testing.T) {
+	w := newWorker(Config{})
+	w.calculatingGap(&cpuStat{})
+	for i := range historySize + 1 {
+		w.calculatingGap(&cpuStat{User: uint64(i+1) * 100, Idle: uint64(i+1) * 100})
+	}
+	base := time.Unix(1000, 0)
+	for _, u := range w.usages[1:] {
+		u.Time = base.Add(time.Duration(u.Seq) * time.Second)
+	}
+
+	samples := w.samples(0, 0)
+	if len(samples) != historySize-1 {
+		t.Fatalf("expected %d samples, got %d", historySize-1, len(samples))
+	}
+	for i, s := range samples[1:] {
+		if s.Seq != samples[i].Seq+1 {
+			t.Fatalf("expected the samples in order, got %d after %d", s.Seq, samples[i].Seq)
+		}
+	}
+	s := samples[len(samples)-1]
+	want := float64(100) / float64(clockTicks())
+	if s.Seq != w.seq || s.Epoch != 1000+int64(w.seq) || s.Usage != 50 || s.User != want || s.Idle != want || s.System != 0 {
+		t.Errorf("unexpected sample: %+v", s)
+	}
+
+	samples = w.samples(1000+int64(w.seq)-2, 1000+int64(w.seq)-1)
+	if len(samples) != 2 || samples[0].Seq != w.seq-2 || samples[1].Seq != w.seq-1 {
+		t.Errorf("unexpected samples in range: %v", samples)
+	}
+}
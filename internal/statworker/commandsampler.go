@@ -0,0 +1,134 @@
+package statworker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultCommandInterval = 10 * time.Second
+	defaultCommandTimeout  = 5 * time.Second
+)
+
+// commandSampler runs a command at an interval and reports the numbers in
+// its "key\tvalue" output lines, as the series of the keys under
+// command.<prefix>, apart from the built-in groups. The command runs in
+// background so that a slow command does not delay the other samplers.
+type commandSampler struct {
+	prefix   string
+	command  string
+	interval time.Duration
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	running bool
+	lastRun time.Time
+	values  map[string]float64
+	err     error
+}
+
+// newCommandSampler returns the sampler of a command entry with the
+// parameters prefix, exec and optional interval and timeout.
+func newCommandSampler(params map[string]string) (*commandSampler, error) {
+	s := &commandSampler{
+		prefix:   params["prefix"],
+		command:  params["exec"],
+		interval: defaultCommandInterval,
+		timeout:  defaultCommandTimeout,
+	}
+	if s.prefix == "" || metricKey(s.prefix) != s.prefix {
+		return nil, fmt.Errorf("invalid prefix %q", s.prefix)
+	}
+	if s.command == "" {
+		return nil, fmt.Errorf("exec is required")
+	}
+	for k, p := range map[string]*time.Duration{"interval": &s.interval, "timeout": &s.timeout} {
+		v, ok := params[k]
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", k, v)
+		}
+		*p = d
+	}
+	for k := range params {
+		if k != "prefix" && k != "exec" && k != "interval" && k != "timeout" {
+			return nil, fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+	return s, nil
+}
+
+func (s *commandSampler) Group() string {
+	return "command." + s.prefix
+}
+
+// Sample starts the command when the interval has passed since the last
+// run, and returns the values of the last run finished since the previous
+// call, or nil.
+func (s *commandSampler) Sample(now time.Time) (map[string]float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running && now.Sub(s.lastRun) >= s.interval {
+		s.running = true
+		s.lastRun = now
+		go s.run()
+	}
+	values, err := s.values, s.err
+	s.values, s.err = nil, nil
+	return values, err
+}
+
+func (s *commandSampler) run() {
+	values, err := s.exec()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+	s.values, s.err = values, err
+}
+
+func (s *commandSampler) exec() (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", s.command)
+	// kill the children of the shell as well, they keep the output open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %q: %w", s.command, err)
+	}
+	return parseCommandOutput(out), nil
+}
+
+// parseCommandOutput parses "key\tvalue" lines. Lines without a number
+// are ignored.
+func parseCommandOutput(out []byte) map[string]float64 {
+	values := map[string]float64{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), "\t")
+		if !ok || k == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			continue
+		}
+		values[metricKey(k)] = f
+	}
+	return values
+}
@@ -0,0 +1,96 @@
+package statworker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCommandOutput(t *testing.T) {
+	got := parseCommandOutput([]byte("queue\t12\nlatency.ms\t1.5\nno value\nname\tabc\n\t3\n"))
+	want := map[string]float64{"queue": 12, "latency_ms": 1.5}
+	if len(got) != len(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+}
+
+// waitSample samples until the command finishes.
+func waitSample(t *testing.T, s *commandSampler, now time.Time) (map[string]float64, error) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		v, err := s.Sample(now)
+		if v != nil {
+			return v, err
+		}
+		if err != nil {
+			return nil, err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("command did not finish")
+	return nil, nil
+}
+
+func TestCommandSampler(t *testing.T) {
+	s, err := newCommandSampler(map[string]string{
+		"prefix":   "redis",
+		"exec":     `printf 'queue\t3\n'`,
+		"interval": "10s",
+	})
+	if err != nil {
+		t.Fatalf("newCommandSampler() error = %v", err)
+	}
+	if s.Group() != "command.redis" {
+		t.Errorf("unexpected group %q", s.Group())
+	}
+	now := time.Now()
+	v, err := waitSample(t, s, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v["queue"] != 3 {
+		t.Errorf("expected queue 3, got %v", v)
+	}
+	// not run again until the interval has passed
+	time.Sleep(50 * time.Millisecond)
+	if v, err := s.Sample(now.Add(5 * time.Second)); v != nil || err != nil {
+		t.Errorf("expected nil within the interval, got %v %v", v, err)
+	}
+	if v, err := waitSample(t, s, now.Add(10*time.Second)); err != nil || v["queue"] != 3 {
+		t.Errorf("expected queue 3 after the interval, got %v %v", v, err)
+	}
+}
+
+func TestCommandSampler_Timeout(t *testing.T) {
+	s, err := newCommandSampler(map[string]string{
+		"prefix":  "slow",
+		"exec":    "sleep 5",
+		"timeout": "100ms",
+	})
+	if err != nil {
+		t.Fatalf("newCommandSampler() error = %v", err)
+	}
+	if _, err := waitSample(t, s, time.Now()); err == nil {
+		t.Error("expected timeout error")
+	}
+}
+
+func TestNewCommandSampler_Invalid(t *testing.T) {
+	for _, params := range []map[string]string{
+		{"exec": "true"},
+		{"prefix": "a.b", "exec": "true"},
+		{"prefix": "a"},
+		{"prefix": "a", "exec": "true", "interval": "0s"},
+		{"prefix": "a", "exec": "true", "timeout": "x"},
+		{"prefix": "a", "exec": "true", "unknown": "1"},
+	} {
+		if _, err := newCommandSampler(params); err == nil {
+			t.Errorf("expected error for %v", params)
+		}
+	}
+}
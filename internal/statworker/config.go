@@ -0,0 +1,63 @@
+package statworker
+
+import (
+	"os"
+)
+
+// Config holds the options of the calculating daemon.
+type Config struct {
+	// GuestCorrection subtracts guest and guest_nice from user and nice,
+	// which the kernel already includes them in.
+	GuestCorrection bool
+	// PhysicalCores reports the usage of each physical core and socket,
+	// taking SMT siblings into account.
+	PhysicalCores bool
+	// NUMA reports the usage of each NUMA node.
+	NUMA bool
+	// CoreSkew reports how unevenly the load is spread over the cpus.
+	CoreSkew bool
+	// CPUGroups are the named cpu groups to report usage of, in the form
+	// NAME=SPEC. See parseCPUGroup for SPEC.
+	CPUGroups []string
+	// SoftIRQs reports the rates of each softirq type from /proc/softirqs.
+	SoftIRQs bool
+	// SoftIRQsPerCPU also reports the rates of each softirq type per cpu.
+	SoftIRQsPerCPU bool
+	// Interrupts reports the busiest IRQs and the IRQ concentration from
+	// /proc/interrupts.
+	Interrupts bool
+	// Schedstat reports the time tasks waited on the run queue from
+	// /proc/schedstat.
+	Schedstat bool
+	// SchedstatPerCPU also reports the run queue waiting time per cpu.
+	SchedstatPerCPU bool
+	// CPUIdle reports the residency of the cpu idle states.
+	CPUIdle bool
+	// CPUFreq reports the cpu frequency and thermal throttle events.
+	CPUFreq bool
+	// FreqWeightedUsage also reports the usage weighted by the ratio of the
+	// current frequency to the max frequency.
+	FreqWeightedUsage bool
+	// RAPL reports the package power from powercap.
+	RAPL bool
+	// Thermal reports the temperature of the thermal zones.
+	Thermal bool
+	// Steal reports the steal time analysis.
+	Steal bool
+	// StealThreshold is the steal percent counted in steal_seconds.
+	StealThreshold float64
+	// Diskstats reports the utilization and IOPS of the block devices.
+	Diskstats bool
+	// NetDev reports the traffic of the network interfaces.
+	NetDev bool
+	// SourcesConfig is the path of the config file of additional sources.
+	// See parseSourceConfig for the format.
+	SourcesConfig string
+}
+
+// IsHypervisor reports whether the kvm module is loaded, in which case guest
+// time is likely to be accounted on this host.
+func IsHypervisor() bool {
+	_, err := os.Stat("/sys/module/kvm")
+	return err == nil
+}
@@ -0,0 +1,162 @@
+package statworker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+type freqCPU struct {
+	CPU int
+	Dir string
+	// MaxFreq is cpuinfo_max_freq in kHz, 0 when unknown
+	MaxFreq uint64
+	// CoreThrottle and PackageThrottle are true for the cpu whose throttle
+	// counter is read on behalf of its core and package, since siblings
+	// share the same counter.
+	CoreThrottle    bool
+	PackageThrottle bool
+}
+
+type cpufreqSampler struct {
+	cpus         []*freqCPU
+	prevCore     map[int]uint64
+	prevPackage  map[int]uint64
+	hasThrottles bool
+	// hasMaxFreq is true when cpuinfo_max_freq of all cpus is known
+	hasMaxFreq bool
+	// weighted reports the usage weighted by the frequency ratio
+	weighted bool
+}
+
+// newCPUFreqSampler finds the cpus with cpufreq under the cpu sysfs
+// directory. thermal_throttle is optional since it is only on x86.
+func newCPUFreqSampler(dir string) (*cpufreqSampler, error) {
+	dirs, err := filepath.Glob(filepath.Join(dir, "cpu[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	s := &cpufreqSampler{hasMaxFreq: true}
+	cores := map[string]bool{}
+	packages := map[string]bool{}
+	for _, d := range dirs {
+		cpu, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(d), "cpu"))
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(d, "cpufreq", "scaling_cur_freq")); err != nil {
+			continue
+		}
+		c := &freqCPU{CPU: cpu, Dir: d}
+		if v, err := readUint(filepath.Join(d, "cpufreq", "cpuinfo_max_freq")); err == nil && v > 0 {
+			c.MaxFreq = v
+		} else {
+			s.hasMaxFreq = false
+		}
+		if _, err := os.Stat(filepath.Join(d, "thermal_throttle")); err == nil {
+			s.hasThrottles = true
+			pkg, _ := os.ReadFile(filepath.Join(d, "topology", "physical_package_id"))
+			core, _ := os.ReadFile(filepath.Join(d, "topology", "core_id"))
+			pkgKey := strings.TrimSpace(string(pkg))
+			coreKey := pkgKey + ":" + strings.TrimSpace(string(core))
+			c.CoreThrottle = !cores[coreKey]
+			c.PackageThrottle = !packages[pkgKey]
+			cores[coreKey] = true
+			packages[pkgKey] = true
+		}
+		s.cpus = append(s.cpus, c)
+	}
+	if len(s.cpus) == 0 {
+		return nil, fmt.Errorf("no cpufreq found in %s", dir)
+	}
+	return s, nil
+}
+
+func (s *cpufreqSampler) Group() string {
+	return "cpufreq"
+}
+
+// Sample reads the current frequencies and the throttle counters. It
+// returns the frequency in MHz averaged over cpus as mhz, the ratio of the
+// sum of scaling_cur_freq to the sum of cpuinfo_max_freq when known, and
+// the throttle events since the previous call as throttle_events.core and
+// throttle_events.package, which are counted from the second call. A cpu
+// not readable, such as gone offline, is left out of the values and its
+// counters are counted again from the next call it is read.
+func (s *cpufreqSampler) Sample(_ time.Time) (map[string]float64, error) {
+	var cur, maxFreq uint64
+	var n int
+	var lastErr error
+	coreCounts := map[int]uint64{}
+	packageCounts := map[int]uint64{}
+	for _, c := range s.cpus {
+		f, err := readUint(filepath.Join(c.Dir, "cpufreq", "scaling_cur_freq"))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		cur += f
+		maxFreq += c.MaxFreq
+		n++
+		if c.CoreThrottle {
+			if v, err := readUint(filepath.Join(c.Dir, "thermal_throttle", "core_throttle_count")); err == nil {
+				coreCounts[c.CPU] = v
+			}
+		}
+		if c.PackageThrottle {
+			if v, err := readUint(filepath.Join(c.Dir, "thermal_throttle", "package_throttle_count")); err == nil {
+				packageCounts[c.CPU] = v
+			}
+		}
+	}
+	if n == 0 {
+		return nil, lastErr
+	}
+	res := map[string]float64{"mhz": float64(cur) / 1000 / float64(n)}
+	if s.hasMaxFreq {
+		res["ratio"] = float64(cur) / float64(maxFreq)
+	}
+	if s.hasThrottles && s.prevCore != nil {
+		var core, pkg uint64
+		for cpu, v := range coreCounts {
+			if p, ok := s.prevCore[cpu]; ok {
+				core += gap(v, p)
+			}
+		}
+		for cpu, v := range packageCounts {
+			if p, ok := s.prevPackage[cpu]; ok {
+				pkg += gap(v, p)
+			}
+		}
+		res["throttle_events.core"] = float64(core)
+		res["throttle_events.package"] = float64(pkg)
+	}
+	s.prevCore, s.prevPackage = coreCounts, packageCounts
+	return res, nil
+}
+
+// Summarized is false for the frequency ratio, which is only reported as
+// the weighted usage.
+func (s *cpufreqSampler) Summarized(series string) bool {
+	return series != "ratio"
+}
+
+// PeakMetrics reports the usage of each second multiplied by the frequency
+// ratio with weighted.
+func (s *cpufreqSampler) PeakMetrics(points []point, epoch int64) []*maxcpu.Metric {
+	if !s.weighted {
+		return nil
+	}
+	var usages []float64
+	for _, p := range points {
+		if r, ok := p.Values["ratio"]; ok {
+			usages = append(usages, p.Usage*r)
+		}
+	}
+	return summarize("freq_weighted_usage", usages, epoch)
+}
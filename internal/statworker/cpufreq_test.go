@@ -0,0 +1,155 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 2 cpus sharing a core, cpu2 without thermal_throttle
+func cpufreqSysfs(t *testing.T) string {
+	t.Helper()
+	return writeSysfs(t, map[string]string{
+		"cpu0/cpufreq/scaling_cur_freq":                "1000000\n",
+		"cpu0/cpufreq/cpuinfo_max_freq":                "4000000\n",
+		"cpu0/thermal_throttle/core_throttle_count":    "5\n",
+		"cpu0/thermal_throttle/package_throttle_count": "7\n",
+		"cpu0/topology/physical_package_id":            "0\n",
+		"cpu0/topology/core_id":                        "0\n",
+		"cpu1/cpufreq/scaling_cur_freq":                "3000000\n",
+		"cpu1/cpufreq/cpuinfo_max_freq":                "4000000\n",
+		"cpu1/thermal_throttle/core_throttle_count":    "5\n",
+		"cpu1/thermal_throttle/package_throttle_count": "7\n",
+		"cpu1/topology/physical_package_id":            "0\n",
+		"cpu1/topology/core_id":                        "0\n",
+		"cpu2/cpufreq/scaling_cur_freq":                "2000000\n",
+		"cpu2/cpufreq/cpuinfo_max_freq":                "4000000\n",
+		"cpufreq/policy0/scaling_cur_freq":             "1000000\n",
+	})
+}
+
+func TestCPUFreqSampler(t *testing.T) {
+	root := cpufreqSysfs(t)
+	s, err := newCPUFreqSampler(root)
+	if err != nil {
+		t.Fatalf("newCPUFreqSampler() error = %v", err)
+	}
+	if len(s.cpus) != 3 || !s.hasThrottles || !s.hasMaxFreq {
+		t.Fatalf("unexpected sampler: %+v", s)
+	}
+	v, err := s.Sample(time.Now())
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if v["mhz"] != 2000 {
+		t.Errorf("unexpected frequency: %v", v)
+	}
+	if v["ratio"] != 0.5 {
+		t.Errorf("expected ratio 0.5, got %v", v)
+	}
+	if _, ok := v["throttle_events.core"]; ok {
+		t.Errorf("expected no throttle events at the first sample, got %v", v)
+	}
+
+	// siblings share the counters
+	for _, name := range []string{"cpu0", "cpu1"} {
+		if err := os.WriteFile(filepath.Join(root, name, "thermal_throttle", "core_throttle_count"), []byte("8\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name, "thermal_throttle", "package_throttle_count"), []byte("8\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err = s.Sample(time.Now())
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if v["throttle_events.core"] != 3 || v["throttle_events.package"] != 1 {
+		t.Errorf("unexpected throttle events: %v", v)
+	}
+}
+
+func TestCPUFreqSampler_Offline(t *testing.T) {
+	root := cpufreqSysfs(t)
+	s, err := newCPUFreqSampler(root)
+	if err != nil {
+		t.Fatalf("newCPUFreqSampler() error = %v", err)
+	}
+	if _, err := s.Sample(time.Now()); err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	// cpu0 goes offline with the throttle counters of its core and package
+	if err := os.RemoveAll(filepath.Join(root, "cpu0")); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Sample(time.Now())
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if v["mhz"] != 2500 || v["ratio"] != 0.625 {
+		t.Errorf("expected the frequency of cpu1 and cpu2 only, got %v", v)
+	}
+	if v["throttle_events.core"] != 0 || v["throttle_events.package"] != 0 {
+		t.Errorf("unexpected throttle events: %v", v)
+	}
+	// counted again from the next sample when cpu0 comes back
+	for name, v := range map[string]string{
+		"cpu0/cpufreq/scaling_cur_freq":                "1000000\n",
+		"cpu0/cpufreq/cpuinfo_max_freq":                "4000000\n",
+		"cpu0/thermal_throttle/core_throttle_count":    "9\n",
+		"cpu0/thermal_throttle/package_throttle_count": "9\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err = s.Sample(time.Now())
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if v["mhz"] != 2000 || v["throttle_events.core"] != 0 || v["throttle_events.package"] != 0 {
+		t.Errorf("expected no throttle events counted from before cpu0 went offline, got %v", v)
+	}
+
+	for _, name := range []string{"cpu0", "cpu1", "cpu2"} {
+		if err := os.RemoveAll(filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Sample(time.Now()); err == nil {
+		t.Error("expected error without readable cpus")
+	}
+}
+
+func TestCPUFreqSampler_NoCPUFreq(t *testing.T) {
+	root := writeSysfs(t, map[string]string{"cpu0/topology/core_id": "0\n"})
+	if _, err := newCPUFreqSampler(root); err == nil {
+		t.Error("expected error without cpufreq")
+	}
+}
+
+func TestFreqPeakMetrics(t *testing.T) {
+	points := []point{
+		{Usage: 100, Values: map[string]float64{"mhz": 2000, "ratio": 0.5}},
+		{Usage: 50, Values: map[string]float64{"mhz": 2000, "ratio": 1}},
+		{Usage: 80, Values: map[string]float64{"mhz": 2000}},
+	}
+	got := map[string]float64{}
+	for _, m := range (&cpufreqSampler{weighted: true}).PeakMetrics(points, 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	if len(got) != 5 || got["freq_weighted_usage.max"] != 50 || got["freq_weighted_usage.min"] != 50 {
+		t.Errorf("unexpected weighted usage: %v", got)
+	}
+	if res := (&cpufreqSampler{}).PeakMetrics(points, 1); len(res) != 0 {
+		t.Errorf("expected no weighted usage, got %v", res)
+	}
+	s := &cpufreqSampler{}
+	if s.Summarized("ratio") || !s.Summarized("mhz") {
+		t.Error("expected only the ratio not summarized")
+	}
+}
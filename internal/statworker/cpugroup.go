@@ -0,0 +1,79 @@
+package statworker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// cgroupRoot is the mount point of the cgroup filesystem
+var cgroupRoot = "/sys/fs/cgroup"
+
+var cpuGroupNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+type cpuGroup struct {
+	Name string
+	CPUs []int
+}
+
+// parseCPUGroup parses a cpu group definition NAME=SPEC. SPEC is one of
+//
+//	a cpu list such as 0-1,4
+//	isolated, the cpus isolated by isolcpus
+//	cgroup:PATH, the effective cpuset of the cgroup at PATH under /sys/fs/cgroup
+func parseCPUGroup(s string) (*cpuGroup, error) {
+	name, spec, ok := strings.Cut(s, "=")
+	if !ok || spec == "" {
+		return nil, fmt.Errorf("invalid cpu group %q: expected NAME=SPEC", s)
+	}
+	if !cpuGroupNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid cpu group name %q", name)
+	}
+	var cpus []int
+	var err error
+	switch {
+	case spec == "isolated":
+		cpus, err = readCPUList(filepath.Join(cpuSysfs, "isolated"))
+	case strings.HasPrefix(spec, "cgroup:"):
+		cpus, err = readCgroupCPUs(strings.TrimPrefix(spec, "cgroup:"))
+	default:
+		cpus, err = parseCPUList(spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cpus of cpu group %q: %w", name, err)
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("cpu group %q has no cpus", name)
+	}
+	return &cpuGroup{Name: name, CPUs: cpus}, nil
+}
+
+// readCgroupCPUs reads the effective cpuset of a cgroup v2, or of the cpuset
+// hierarchy of cgroup v1.
+func readCgroupCPUs(path string) ([]int, error) {
+	cpus, err := readCPUList(filepath.Join(cgroupRoot, path, "cpuset.cpus.effective"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return readCPUList(filepath.Join(cgroupRoot, "cpuset", path, "cpuset.effective_cpus"))
+	}
+	return cpus, err
+}
+
+// cpuGroupMetrics summarizes the usage of each cpu group in the samples.
+func cpuGroupMetrics(groups []*cpuGroup, samples []*cpuUsage, epoch int64) []*maxcpu.Metric {
+	var res []*maxcpu.Metric
+	for _, g := range groups {
+		var usages []float64
+		for _, u := range samples {
+			if usage, ok := cpuSetUsage(g.CPUs, u); ok {
+				usages = append(usages, usage)
+			}
+		}
+		res = append(res, summarize("cpu_group_usage."+g.Name, usages, epoch)...)
+	}
+	return res
+}
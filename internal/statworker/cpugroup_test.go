@@ -0,0 +1,73 @@
+package statworker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUGroup(t *testing.T) {
+	sysfs := writeSysfs(t, map[string]string{
+		"isolated": "2-3\n",
+	})
+	cgroup := writeSysfs(t, map[string]string{
+		"web.slice/cpuset.cpus.effective":        "4-5\n",
+		"cpuset/batch/cpuset.effective_cpus":     "6\n",
+		"empty.slice/cpuset.cpus.effective":      "\n",
+		"cpuset/web.slice/cpuset.effective_cpus": "7\n",
+	})
+	defer func(s, c string) { cpuSysfs, cgroupRoot = s, c }(cpuSysfs, cgroupRoot)
+	cpuSysfs, cgroupRoot = sysfs, cgroup
+
+	tests := []struct {
+		input    string
+		expected []int
+		wantErr  bool
+	}{
+		{"housekeeping=0-1", []int{0, 1}, false},
+		{"isolated=isolated", []int{2, 3}, false},
+		{"web=cgroup:/web.slice", []int{4, 5}, false},
+		{"batch=cgroup:batch", []int{6}, false},
+		{"empty=cgroup:/empty.slice", nil, true},
+		{"missing=cgroup:/missing.slice", nil, true},
+		{"housekeeping", nil, true},
+		{"house.keeping=0-1", nil, true},
+		{"=0-1", nil, true},
+		{"bad=a-b", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCPUGroup(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCPUGroup(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got.CPUs, tt.expected) {
+			t.Errorf("parseCPUGroup(%q) = %v, want %v", tt.input, got.CPUs, tt.expected)
+		}
+	}
+}
+
+func TestCPUGroupMetrics(t *testing.T) {
+	groups := []*cpuGroup{
+		{Name: "housekeeping", CPUs: []int{0, 1}},
+		{Name: "isolated", CPUs: []int{2, 3}},
+	}
+	samples := []*cpuUsage{
+		{CPUs: map[int]*cpuUsage{0: {Busy: 100, Total: 100}, 1: {Busy: 90, Total: 100}, 2: {Total: 100}, 3: {Total: 100}}},
+		{CPUs: map[int]*cpuUsage{0: {Busy: 50, Total: 100}, 1: {Busy: 50, Total: 100}, 2: {Total: 100}, 3: {Busy: 2, Total: 100}}},
+	}
+	got := map[string]float64{}
+	for _, m := range cpuGroupMetrics(groups, samples, 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	want := map[string]float64{
+		"cpu_group_usage.housekeeping.max": 95,
+		"cpu_group_usage.housekeeping.min": 50,
+		"cpu_group_usage.isolated.max":     1,
+		"cpu_group_usage.isolated.min":     0,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+}
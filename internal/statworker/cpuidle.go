@@ -0,0 +1,210 @@
+package statworker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+type idleState struct {
+	CPU  int
+	Name string
+	Dir  string
+	// Deepest is true for the deepest state of the cpu
+	Deepest bool
+}
+
+// idleCounter holds the cumulative time in microseconds and entry count of
+// an idle state
+type idleCounter struct {
+	Time  uint64
+	Usage uint64
+}
+
+// findIdleStates lists cpuN/cpuidle/stateM under the cpu sysfs directory.
+func findIdleStates(dir string) ([]*idleState, error) {
+	cpuDirs, err := filepath.Glob(filepath.Join(dir, "cpu[0-9]*", "cpuidle"))
+	if err != nil {
+		return nil, err
+	}
+	var states []*idleState
+	for _, cpuDir := range cpuDirs {
+		cpu, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(cpuDir)), "cpu"))
+		if err != nil {
+			continue
+		}
+		stateDirs, err := filepath.Glob(filepath.Join(cpuDir, "state[0-9]*"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(stateDirs, func(i, j int) bool {
+			return stateIndex(stateDirs[i]) < stateIndex(stateDirs[j])
+		})
+		for i, stateDir := range stateDirs {
+			b, err := os.ReadFile(filepath.Join(stateDir, "name"))
+			if err != nil {
+				return nil, err
+			}
+			states = append(states, &idleState{
+				CPU:     cpu,
+				Name:    metricKey(strings.TrimSpace(string(b))),
+				Dir:     stateDir,
+				Deepest: i == len(stateDirs)-1,
+			})
+		}
+	}
+	if len(states) == 0 {
+		return nil, fmt.Errorf("no cpuidle states found in %s", dir)
+	}
+	return states, nil
+}
+
+func stateIndex(dir string) int {
+	i, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "state"))
+	return i
+}
+
+func readIdleCounter(dir string) (idleCounter, error) {
+	var c idleCounter
+	for _, f := range []struct {
+		name string
+		v    *uint64
+	}{{"time", &c.Time}, {"usage", &c.Usage}} {
+		b, err := os.ReadFile(filepath.Join(dir, f.name))
+		if err != nil {
+			return c, err
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			return c, err
+		}
+		*f.v = v
+	}
+	return c, nil
+}
+
+type cpuidleSampler struct {
+	states []*idleState
+	// prev holds the counters of each state, nil when it was not readable
+	prev     []*idleCounter
+	prevTime time.Time
+}
+
+func newCPUIdleSampler(dir string) (*cpuidleSampler, error) {
+	states, err := findIdleStates(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &cpuidleSampler{states: states}, nil
+}
+
+func (s *cpuidleSampler) Group() string {
+	return "cpuidle"
+}
+
+// Sample reads the idle states and returns, since the previous call, the
+// residency in percent of each state averaged over cpus as residency.state,
+// the entries per second to each state on all cpus as entries.state, and
+// the residency of the deepest state of the least idle cpu as
+// deep_residency and of each cpu as deep.cpuN. The min of deep_residency is
+// thus the lowest of all cpus in a second. It returns nil at the first
+// call. A state not readable, such as of a cpu gone offline, is skipped in
+// this and the next sample.
+func (s *cpuidleSampler) Sample(now time.Time) (map[string]float64, error) {
+	counters := make([]*idleCounter, len(s.states))
+	var lastErr error
+	for i, st := range s.states {
+		c, err := readIdleCounter(st.Dir)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		counters[i] = &c
+	}
+	if lastErr != nil && !slices.ContainsFunc(counters, func(c *idleCounter) bool { return c != nil }) {
+		return nil, lastErr
+	}
+	prev, prevTime := s.prev, s.prevTime
+	s.prev, s.prevTime = counters, now
+	if prev == nil {
+		return nil, nil
+	}
+	elapsed := float64(now.Sub(prevTime).Microseconds())
+	if elapsed <= 0 {
+		return nil, nil
+	}
+	states := map[string]float64{}
+	entries := map[string]float64{}
+	deep := map[int]float64{}
+	cpus := map[int]bool{}
+	for i, st := range s.states {
+		if counters[i] == nil || prev[i] == nil {
+			continue
+		}
+		cpus[st.CPU] = true
+		residency := float64(gap(counters[i].Time, prev[i].Time)) / elapsed * 100.0
+		states[st.Name] += residency
+		entries[st.Name] += float64(gap(counters[i].Usage, prev[i].Usage)) / elapsed * 1e6
+		if st.Deepest {
+			deep[st.CPU] = residency
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, nil
+	}
+	res := map[string]float64{}
+	for name, residency := range states {
+		res["residency."+name] = residency / float64(len(cpus))
+		res["entries."+name] = entries[name]
+	}
+	if len(deep) > 0 {
+		lowest := -1.0
+		for cpu, residency := range deep {
+			res[fmt.Sprintf("deep.cpu%d", cpu)] = residency
+			if lowest < 0 || residency < lowest {
+				lowest = residency
+			}
+		}
+		res["deep_residency"] = lowest
+	}
+	return res, nil
+}
+
+// Summarized is false for the residency of each cpu, reported only as the
+// least idle cpu.
+func (s *cpuidleSampler) Summarized(series string) bool {
+	return !strings.HasPrefix(series, "deep.")
+}
+
+// PeakMetrics reports min_cpu, the residency of the deepest state averaged
+// over the period of the least idle cpu.
+func (s *cpuidleSampler) PeakMetrics(points []point, epoch int64) []*maxcpu.Metric {
+	total := map[string]float64{}
+	n := map[string]int{}
+	for _, p := range points {
+		for name, residency := range p.Values {
+			if !s.Summarized(name) {
+				total[name] += residency
+				n[name]++
+			}
+		}
+	}
+	if len(total) == 0 {
+		return nil
+	}
+	cpuMin := -1.0
+	for name, residency := range total {
+		avg := residency / float64(n[name])
+		if cpuMin < 0 || avg < cpuMin {
+			cpuMin = avg
+		}
+	}
+	return []*maxcpu.Metric{{Group: "cpuidle.deep_residency", Key: "min_cpu", Metric: cpuMin, Epoch: epoch}}
+}
@@ -0,0 +1,171 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func cpuidleSysfs(t *testing.T) string {
+	t.Helper()
+	return writeSysfs(t, map[string]string{
+		"cpu0/cpuidle/state0/name":  "POLL\n",
+		"cpu0/cpuidle/state0/time":  "0\n",
+		"cpu0/cpuidle/state0/usage": "0\n",
+		"cpu0/cpuidle/state1/name":  "C1\n",
+		"cpu0/cpuidle/state1/time":  "0\n",
+		"cpu0/cpuidle/state1/usage": "0\n",
+		"cpu0/cpuidle/state2/name":  "C6\n",
+		"cpu0/cpuidle/state2/time":  "0\n",
+		"cpu0/cpuidle/state2/usage": "0\n",
+		"cpu1/cpuidle/state0/name":  "POLL\n",
+		"cpu1/cpuidle/state0/time":  "0\n",
+		"cpu1/cpuidle/state0/usage": "0\n",
+		"cpu1/cpuidle/state1/name":  "C1\n",
+		"cpu1/cpuidle/state1/time":  "0\n",
+		"cpu1/cpuidle/state1/usage": "0\n",
+		"cpu1/cpuidle/state2/name":  "C6\n",
+		"cpu1/cpuidle/state2/time":  "0\n",
+		"cpu1/cpuidle/state2/usage": "0\n",
+		"online":                    "0-1\n",
+	})
+}
+
+func TestFindIdleStates(t *testing.T) {
+	states, err := findIdleStates(cpuidleSysfs(t))
+	if err != nil {
+		t.Fatalf("findIdleStates() error = %v", err)
+	}
+	if len(states) != 6 {
+		t.Fatalf("expected 6 states, got %d", len(states))
+	}
+	if states[2].Name != "C6" || !states[2].Deepest || states[1].Deepest {
+		t.Errorf("unexpected states: %+v %+v", states[1], states[2])
+	}
+	if _, err := findIdleStates(t.TempDir()); err == nil {
+		t.Error("expected error without cpuidle states")
+	}
+}
+
+func TestCPUIdleSampler(t *testing.T) {
+	root := cpuidleSysfs(t)
+	s, err := newCPUIdleSampler(root)
+	if err != nil {
+		t.Fatalf("newCPUIdleSampler() error = %v", err)
+	}
+	now := time.Now()
+	if v, err := s.Sample(now); err != nil || v != nil {
+		t.Fatalf("expected nil at first sample, got %v %v", v, err)
+	}
+	// cpu0 spends 80% in C6, cpu1 20% in C1 and 40% in C6 over 1s
+	for name, v := range map[string]string{
+		"cpu0/cpuidle/state2/time":  "800000\n",
+		"cpu0/cpuidle/state2/usage": "10\n",
+		"cpu1/cpuidle/state1/time":  "200000\n",
+		"cpu1/cpuidle/state1/usage": "100\n",
+		"cpu1/cpuidle/state2/time":  "400000\n",
+		"cpu1/cpuidle/state2/usage": "5\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := s.Sample(now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if v["residency.C6"] != 60 || v["residency.C1"] != 10 || v["residency.POLL"] != 0 {
+		t.Errorf("unexpected residency: %v", v)
+	}
+	if v["entries.C1"] != 100 || v["entries.C6"] != 15 {
+		t.Errorf("unexpected entries: %v", v)
+	}
+	if v["deep.cpu0"] != 80 || v["deep.cpu1"] != 40 || v["deep_residency"] != 40 {
+		t.Errorf("unexpected deep residency: %v", v)
+	}
+}
+
+func TestCPUIdleSampler_Offline(t *testing.T) {
+	root := cpuidleSysfs(t)
+	s, err := newCPUIdleSampler(root)
+	if err != nil {
+		t.Fatalf("newCPUIdleSampler() error = %v", err)
+	}
+	now := time.Now()
+	if _, err := s.Sample(now); err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	// cpu1 goes offline, cpu0 spends 50% in C6
+	if err := os.RemoveAll(filepath.Join(root, "cpu1", "cpuidle")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "cpu0/cpuidle/state2/time"), []byte("500000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Sample(now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if _, ok := v["deep.cpu1"]; ok || v["residency.C6"] != 50 || v["deep.cpu0"] != 50 {
+		t.Errorf("expected the residency of cpu0 only, got %v", v)
+	}
+
+	if err := os.RemoveAll(filepath.Join(root, "cpu0", "cpuidle")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Sample(now.Add(2 * time.Second)); err == nil {
+		t.Error("expected error without readable states")
+	}
+}
+
+func TestCPUIdleMetrics_DeepResidency(t *testing.T) {
+	root := cpuidleSysfs(t)
+	s, err := newCPUIdleSampler(root)
+	if err != nil {
+		t.Fatalf("newCPUIdleSampler() error = %v", err)
+	}
+	w := newWorker(Config{})
+	w.addSampler(s)
+	now := time.Now()
+	sample := func(i int, cpu0, cpu1 string) {
+		for name, v := range map[string]string{"cpu0/cpuidle/state2/time": cpu0, "cpu1/cpuidle/state2/time": cpu1} {
+			if err := os.WriteFile(filepath.Join(root, name), []byte(v), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		v, err := s.Sample(now.Add(time.Duration(i) * time.Second))
+		if err != nil {
+			t.Fatalf("Sample() error = %v", err)
+		}
+		w.store([]map[string]float64{w.procStat.add(&cpuStat{User: uint64(i), Idle: uint64(i)}), v})
+	}
+	sample(0, "0\n", "0\n")
+	// cpu0 10% and cpu1 90% in C6, then cpu0 90% and cpu1 30%
+	sample(1, "100000\n", "900000\n")
+	sample(2, "1000000\n", "1200000\n")
+
+	got := map[string]float64{}
+	for _, m := range w.samplerMetrics(w.samplesSince(0), 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	// the lowest of all cpus in a second, and the least idle cpu over the period
+	if got["cpuidle.deep_residency.min"] != 10 || got["cpuidle.deep_residency.max"] != 30 || got["cpuidle.deep_residency.min_cpu"] != 50 {
+		t.Errorf("unexpected deep residency: %v", got)
+	}
+}
+
+func TestIdlePeakMetrics(t *testing.T) {
+	points := []point{
+		{Usage: 100, Values: map[string]float64{"deep_residency": 40, "deep.cpu0": 80, "deep.cpu1": 40}},
+		{Usage: 50, Values: map[string]float64{"deep_residency": 0, "deep.cpu0": 0, "deep.cpu1": 0}},
+	}
+	s := &cpuidleSampler{}
+	res := s.PeakMetrics(points, 1)
+	if len(res) != 1 || res[0].Group != "cpuidle.deep_residency" || res[0].Key != "min_cpu" || res[0].Metric != 20 {
+		t.Errorf("unexpected metrics: %v", res)
+	}
+	if s.Summarized("deep.cpu0") || !s.Summarized("deep_residency") {
+		t.Error("expected only the residency of each cpu not summarized")
+	}
+}
@@ -0,0 +1,70 @@
+package statworker
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseCPUList parses the kernel cpu list format such as "0-3,8,10-11".
+// See "List format" in cpuset(7).
+func parseCPUList(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var cpus []int
+	for _, r := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(r, "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q: %w", s, err)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(to)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpu list %q: %w", s, err)
+			}
+		}
+		if end < start {
+			return nil, fmt.Errorf("invalid cpu list %q: %d-%d", s, start, end)
+		}
+		for i := start; i <= end; i++ {
+			cpus = append(cpus, i)
+		}
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+func readCPUList(path string) ([]int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCPUList(string(b))
+}
+
+func readInt(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func readUint(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// parseUint parses a decimal counter of a /proc or sysfs file.
+func parseUint(b []byte) (uint64, error) {
+	return strconv.ParseUint(string(b), 10, 64)
+}
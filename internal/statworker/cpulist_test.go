@@ -0,0 +1,33 @@
+package statworker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []int
+		wantErr  bool
+	}{
+		{"0", []int{0}, false},
+		{"0-3\n", []int{0, 1, 2, 3}, false},
+		{"8,0-1,4", []int{0, 1, 4, 8}, false},
+		{"", nil, false},
+		{"3-1", nil, true},
+		{"a-b", nil, true},
+		{"1-", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCPUList(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCPUList(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("parseCPUList(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package statworker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// diskStat holds the counters of a device in /proc/diskstats
+type diskStat struct {
+	Reads  uint64
+	Writes uint64
+	// IOTicks is the milliseconds spent doing I/Os
+	IOTicks uint64
+}
+
+// readDiskstats parses /proc/diskstats. Loop and ram devices are skipped.
+//
+//	259       0 nvme0n1 10740 2955 1126396 2352 84316 54853 4143848 43528 0 69680 49044 0 0 0 0
+func readDiskstats(r io.Reader) (map[string]*diskStat, error) {
+	disks := map[string]*diskStat{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		sp := bytes.Fields(s.Bytes())
+		if len(sp) < 14 {
+			continue
+		}
+		name := string(sp[2])
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		d := &diskStat{}
+		for _, f := range []struct {
+			v   *uint64
+			idx int
+		}{{&d.Reads, 3}, {&d.Writes, 7}, {&d.IOTicks, 12}} {
+			v, err := parseUint(sp[f.idx])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+			*f.v = v
+		}
+		disks[name] = d
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scanner error: %w", err)
+	}
+	return disks, nil
+}
+
+type diskstatsSampler struct {
+	path     string
+	prev     map[string]*diskStat
+	prevTime time.Time
+}
+
+func newDiskstatsSampler() *diskstatsSampler {
+	return &diskstatsSampler{path: "/proc/diskstats"}
+}
+
+func (s *diskstatsSampler) Group() string {
+	return "disk"
+}
+
+// Sample reads /proc/diskstats and returns the utilization in percent as
+// util.device and the IOPS as iops.device of each device since the
+// previous call. It returns nil at the first call.
+func (s *diskstatsSampler) Sample(now time.Time) (map[string]float64, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	disks, err := readDiskstats(f)
+	if err != nil {
+		return nil, err
+	}
+	prev, prevTime := s.prev, s.prevTime
+	s.prev, s.prevTime = disks, now
+	if prev == nil {
+		return nil, nil
+	}
+	elapsed := now.Sub(prevTime).Seconds()
+	if elapsed <= 0 {
+		return nil, nil
+	}
+	rates := map[string]float64{}
+	for name, d := range disks {
+		p, ok := prev[name]
+		if !ok {
+			continue
+		}
+		key := metricKey(name)
+		ios := gap(d.Reads, p.Reads) + gap(d.Writes, p.Writes)
+		rates["util."+key] = min(float64(gap(d.IOTicks, p.IOTicks))/(elapsed*1000)*100, 100)
+		rates["iops."+key] = float64(ios) / elapsed
+	}
+	return rates, nil
+}
+
+func (s *diskstatsSampler) Summarized(string) bool {
+	return true
+}
+
+// PeakMetrics reports the device with the highest utilization at the second
+// the cpu usage peaked.
+func (s *diskstatsSampler) PeakMetrics(points []point, epoch int64) []*maxcpu.Metric {
+	p, ok := peakPoint(points)
+	if !ok {
+		return nil
+	}
+	busiest := ""
+	for _, name := range sortedKeys(p.Values) {
+		if !strings.HasPrefix(name, "util.") {
+			continue
+		}
+		if busiest == "" || p.Values[name] > p.Values[busiest] {
+			busiest = name
+		}
+	}
+	if busiest == "" {
+		return nil
+	}
+	return []*maxcpu.Metric{{
+		Group:  "disk_busiest_at_peak",
+		Key:    strings.TrimPrefix(busiest, "util."),
+		Metric: p.Values[busiest],
+		Epoch:  epoch,
+	}}
+}
@@ -0,0 +1,74 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDiskstats = `   7       0 loop0 100 0 200 10 0 0 0 0 0 10 10 0 0 0 0
+ 259       0 nvme0n1 1000 0 8000 100 2000 0 16000 200 0 500 300 0 0 0 0
+ 259       1 nvme0n1p1 900 0 7000 90 1900 0 15000 190 0 450 280 0 0 0 0
+   8       0 sda 10 0 80 1 20 0 160 2 0 5 3
+`
+
+func TestReadDiskstats(t *testing.T) {
+	disks, err := readDiskstats(strings.NewReader(testDiskstats))
+	if err != nil {
+		t.Fatalf("readDiskstats() error = %v", err)
+	}
+	if len(disks) != 3 {
+		t.Errorf("expected 3 devices without loop0, got %v", disks)
+	}
+	if d := disks["nvme0n1"]; d == nil || d.Reads != 1000 || d.Writes != 2000 || d.IOTicks != 500 {
+		t.Errorf("unexpected nvme0n1: %+v", d)
+	}
+	if _, err := readDiskstats(strings.NewReader("8 0 sda x 0 0 0 0 0 0 0 0 0 0\n")); err == nil {
+		t.Error("expected error for invalid counter")
+	}
+}
+
+func TestDiskstatsSampler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diskstats")
+	if err := os.WriteFile(path, []byte(testDiskstats), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newDiskstatsSampler()
+	s.path = path
+	now := time.Now()
+	if v, err := s.Sample(now); err != nil || v != nil {
+		t.Fatalf("expected nil at first sample, got %v %v", v, err)
+	}
+
+	next := strings.Replace(testDiskstats, "nvme0n1 1000 0 8000 100 2000 0 16000 200 0 500", "nvme0n1 1100 0 8000 100 2300 0 16000 200 0 1300", 1)
+	if err := os.WriteFile(path, []byte(next), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Sample(now.Add(2 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v["util.nvme0n1"] != 40 || v["iops.nvme0n1"] != 200 {
+		t.Errorf("unexpected nvme0n1 rate: %v", v)
+	}
+	if u, ok := v["util.sda"]; !ok || u != 0 || v["iops.sda"] != 0 {
+		t.Errorf("unexpected sda rate: %v", v)
+	}
+}
+
+func TestDiskPeakMetrics(t *testing.T) {
+	points := []point{
+		{Usage: 50, Values: map[string]float64{"util.sda": 90, "iops.sda": 100, "util.dm-0": 10, "iops.dm-0": 300}},
+		{Usage: 80, Values: map[string]float64{"util.sda": 20, "iops.sda": 50, "util.dm-0": 60, "iops.dm-0": 200}},
+	}
+	s := newDiskstatsSampler()
+	res := s.PeakMetrics(points, 1)
+	if len(res) != 1 || res[0].Group != "disk_busiest_at_peak" || res[0].Key != "dm-0" || res[0].Metric != 60 {
+		t.Errorf("unexpected metrics: %v", res)
+	}
+	if m := s.PeakMetrics(nil, 1); m != nil {
+		t.Errorf("expected nil without samples, got %v", m)
+	}
+}
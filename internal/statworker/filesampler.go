@@ -0,0 +1,112 @@
+package statworker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileSampler reads a number from a file such as a sysfs attribute. A gauge
+// reports the value, and a counter, an unsigned integer, reports the rate
+// per second.
+type fileSampler struct {
+	name     string
+	path     string
+	scale    float64
+	counter  bool
+	prev     uint64
+	prevTime time.Time
+}
+
+// newFileSampler returns the sampler of a gauge or counter entry with the
+// parameters name, path and optional scale.
+func newFileSampler(counter bool, params map[string]string) (*fileSampler, error) {
+	s := &fileSampler{
+		name:    params["name"],
+		path:    params["path"],
+		scale:   1,
+		counter: counter,
+	}
+	if s.name == "" || metricKey(s.name) != s.name {
+		return nil, fmt.Errorf("invalid name %q", s.name)
+	}
+	if s.path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if v, ok := params["scale"]; ok {
+		scale, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scale %q: %w", v, err)
+		}
+		s.scale = scale
+	}
+	for k := range params {
+		if k != "name" && k != "path" && k != "scale" {
+			return nil, fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+	var err error
+	if counter {
+		_, err = s.readCounter()
+	} else {
+		_, err = s.read()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSampler) Group() string {
+	return "file." + s.name
+}
+
+func (s *fileSampler) read() (float64, error) {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	return v, nil
+}
+
+func (s *fileSampler) readCounter() (uint64, error) {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := parseUint(bytes.TrimSpace(b))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	return v, nil
+}
+
+// Sample returns the scaled value as the series "". A counter returns nil
+// at the first call.
+func (s *fileSampler) Sample(now time.Time) (map[string]float64, error) {
+	if !s.counter {
+		v, err := s.read()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]float64{"": v * s.scale}, nil
+	}
+	v, err := s.readCounter()
+	if err != nil {
+		return nil, err
+	}
+	prev, prevTime := s.prev, s.prevTime
+	s.prev, s.prevTime = v, now
+	elapsed := now.Sub(prevTime).Seconds()
+	if prevTime.IsZero() || elapsed <= 0 {
+		return nil, nil
+	}
+	return map[string]float64{"": float64(gap(v, prev)) / elapsed * s.scale}, nil
+}
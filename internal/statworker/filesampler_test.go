@@ -0,0 +1,103 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSampler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "temp1_input")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("45000\n")
+
+	s, err := newFileSampler(false, map[string]string{"name": "nvme_temp", "path": path, "scale": "0.001"})
+	if err != nil {
+		t.Fatalf("newFileSampler() error = %v", err)
+	}
+	v, err := s.Sample(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v[""] != 45 {
+		t.Errorf("expected 45, got %v", v)
+	}
+
+	write("invalid\n")
+	if _, err := s.Sample(time.Now()); err == nil {
+		t.Error("expected error for invalid value")
+	}
+}
+
+func TestFileSampler_Counter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "count")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("100\n")
+
+	s, err := newFileSampler(true, map[string]string{"name": "count", "path": path})
+	if err != nil {
+		t.Fatalf("newFileSampler() error = %v", err)
+	}
+	now := time.Now()
+	if v, err := s.Sample(now); err != nil || v != nil {
+		t.Fatalf("expected nil at first sample, got %v %v", v, err)
+	}
+	write("300\n")
+	v, err := s.Sample(now.Add(2 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v[""] != 100 {
+		t.Errorf("expected 100 per second, got %v", v)
+	}
+	write("50\n")
+	if v, err := s.Sample(now.Add(3 * time.Second)); err != nil || v[""] != 0 {
+		t.Errorf("expected no progress when the counter went backwards, got %v %v", v, err)
+	}
+	// beyond the precision of float64
+	write("18446744073709551000\n")
+	if _, err := s.Sample(now.Add(4 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	write("18446744073709551001\n")
+	if v, err := s.Sample(now.Add(5 * time.Second)); err != nil || v[""] != 1 {
+		t.Errorf("expected 1 per second, got %v %v", v, err)
+	}
+	write("1.5\n")
+	if _, err := s.Sample(now.Add(6 * time.Second)); err == nil {
+		t.Error("expected error for a counter not an integer")
+	}
+}
+
+func TestNewFileSampler_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "value")
+	if err := os.WriteFile(path, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, params := range []map[string]string{
+		{"path": path},
+		{"name": "a.b", "path": path},
+		{"name": "a"},
+		{"name": "a", "path": path, "scale": "x"},
+		{"name": "a", "path": path, "unknown": "1"},
+	} {
+		if _, err := newFileSampler(false, params); err == nil {
+			t.Errorf("expected error for %v", params)
+		}
+	}
+	if err := os.WriteFile(path, []byte("1.5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFileSampler(true, map[string]string{"name": "a", "path": path}); err == nil {
+		t.Error("expected error for a counter not an integer")
+	}
+}
@@ -0,0 +1,210 @@
+package statworker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// irqTopN is the number of IRQs reported at the peak of cpu usage
+const irqTopN = 5
+
+// irqStat holds the cumulative counters of the device IRQs in
+// /proc/interrupts, in the order of CPUs.
+type irqStat struct {
+	CPUs   []int
+	Counts map[string][]uint64
+	// Names are the metric safe names of the IRQs such as irq24_eth0
+	Names map[string]string
+}
+
+// readInterrupts parses the numbered device IRQs of /proc/interrupts.
+// Architecture specific interrupts such as LOC are skipped, since they are
+// spread over all cpus regardless of affinity.
+//
+//	           CPU0       CPU1
+//	 24:          1          0  IO-APIC   5-edge      ACPI:Ged
+//	LOC:     221725     200012  Local timer interrupts
+func readInterrupts(r io.Reader) (*irqStat, error) {
+	s := bufio.NewScanner(r)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, fmt.Errorf("scanner error: %w", err)
+		}
+		return nil, fmt.Errorf("no header found in /proc/interrupts")
+	}
+	cpus, err := parseCPUHeader(s.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	st := &irqStat{CPUs: cpus, Counts: map[string][]uint64{}, Names: map[string]string{}}
+	for s.Scan() {
+		sp := bytes.Fields(s.Bytes())
+		if len(sp) < 2 {
+			continue
+		}
+		irq := strings.TrimSuffix(string(sp[0]), ":")
+		if _, err := strconv.Atoi(irq); err != nil {
+			continue
+		}
+		counts := make([]uint64, 0, len(cpus))
+		for _, b := range sp[1:] {
+			if len(counts) == len(cpus) {
+				break
+			}
+			c, err := parseUint(b)
+			if err != nil {
+				return nil, err
+			}
+			counts = append(counts, c)
+		}
+		name := "irq" + irq
+		if desc := sp[1+len(counts):]; len(desc) > 0 {
+			name += "_" + string(desc[len(desc)-1])
+		}
+		st.Counts[irq] = counts
+		st.Names[irq] = metricKey(name)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scanner error: %w", err)
+	}
+	return st, nil
+}
+
+type irqSampler struct {
+	path     string
+	prev     *irqStat
+	prevTime time.Time
+}
+
+func newIRQSampler() *irqSampler {
+	return &irqSampler{path: "/proc/interrupts"}
+}
+
+func (s *irqSampler) Group() string {
+	return "irq_concentration"
+}
+
+// Sample reads /proc/interrupts and returns the IRQ concentration since the
+// previous call, the share of interrupts landing on the busiest cpu in
+// percent. The rate per second of each IRQ is returned by name, and of the
+// pairs of an IRQ and a cpu with interrupts as name.cpuN, since an IRQ
+// usually lands on a few cpus of its affinity. It returns nil at the first
+// call.
+func (s *irqSampler) Sample(now time.Time) (map[string]float64, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := readInterrupts(f)
+	if err != nil {
+		return nil, err
+	}
+	prev, prevTime := s.prev, s.prevTime
+	s.prev, s.prevTime = st, now
+	if prev == nil {
+		return nil, nil
+	}
+	elapsed := now.Sub(prevTime).Seconds()
+	if elapsed <= 0 {
+		return nil, nil
+	}
+	rates := map[string]float64{}
+	cpus := map[int]float64{}
+	for irq, counts := range st.Counts {
+		p := prev.Counts[irq]
+		name := st.Names[irq]
+		var total uint64
+		for i, c := range counts {
+			var d uint64
+			if i < len(p) {
+				d = gap(c, p[i])
+			}
+			total += d
+			cpus[st.CPUs[i]] += float64(d) / elapsed
+			if d > 0 {
+				rates[fmt.Sprintf("%s.cpu%d", name, st.CPUs[i])] += float64(d) / elapsed
+			}
+		}
+		rates[name] += float64(total) / elapsed
+	}
+	var total, busiest float64
+	for _, rate := range cpus {
+		total += rate
+		busiest = max(busiest, rate)
+	}
+	if total > 0 {
+		rates[""] = busiest / total * 100.0
+	}
+	return rates, nil
+}
+
+// Summarized is true only for the concentration, the rates are reported at
+// the peak.
+func (s *irqSampler) Summarized(series string) bool {
+	return series == ""
+}
+
+// PeakMetrics reports the irqTopN busiest IRQs and pairs of an IRQ and a
+// cpu at the second the cpu usage peaked, to show which IRQ is hitting
+// which cpu.
+func (s *irqSampler) PeakMetrics(points []point, epoch int64) []*maxcpu.Metric {
+	p, ok := peakPoint(points)
+	if !ok {
+		return nil
+	}
+	var irqs, pairs []string
+	for _, name := range sortedKeys(p.Values) {
+		switch {
+		case name == "":
+		case strings.Contains(name, "."):
+			pairs = append(pairs, name)
+		default:
+			irqs = append(irqs, name)
+		}
+	}
+	var res []*maxcpu.Metric
+	for _, name := range topSeries(p.Values, irqs) {
+		res = append(res, &maxcpu.Metric{
+			Group:  "irq_top_rate_at_peak",
+			Key:    name,
+			Metric: p.Values[name],
+			Epoch:  epoch,
+		})
+	}
+	for _, pair := range topSeries(p.Values, pairs) {
+		name, cpu, _ := strings.Cut(pair, ".")
+		res = append(res, &maxcpu.Metric{
+			Group:  "irq_cpu_top_rate_at_peak." + name,
+			Key:    cpu,
+			Metric: p.Values[pair],
+			Epoch:  epoch,
+		})
+	}
+	return res
+}
+
+// topSeries returns up to irqTopN of the names with the highest nonzero
+// values, the busiest first.
+func topSeries(values map[string]float64, names []string) []string {
+	sort.SliceStable(names, func(i, j int) bool {
+		return values[names[i]] > values[names[j]]
+	})
+	var top []string
+	for _, name := range names[:min(irqTopN, len(names))] {
+		if values[name] == 0 {
+			break
+		}
+		top = append(top, name)
+	}
+	return top
+}
@@ -0,0 +1,107 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testInterrupts = `           CPU0       CPU1
+  0:         36          0   IO-APIC   2-edge      timer
+ 24:       1000          0  PCI-MSI 524288-edge      eth0-TxRx-0
+ 25:          0       1000  PCI-MSI 524289-edge      eth0-TxRx-1
+ 26:         10         10   IO-APIC   4-edge      ACPI:Ged
+NMI:          0          0   Non-maskable interrupts
+LOC:     221725     200012   Local timer interrupts
+ERR:          0
+`
+
+func TestReadInterrupts(t *testing.T) {
+	st, err := readInterrupts(strings.NewReader(testInterrupts))
+	if err != nil {
+		t.Fatalf("readInterrupts() error = %v", err)
+	}
+	if len(st.Counts) != 4 {
+		t.Errorf("expected 4 device IRQs, got %v", st.Counts)
+	}
+	if c := st.Counts["25"]; len(c) != 2 || c[1] != 1000 {
+		t.Errorf("unexpected counts of IRQ 25: %v", c)
+	}
+	if st.Names["24"] != "irq24_eth0-TxRx-0" || st.Names["26"] != "irq26_ACPI_Ged" {
+		t.Errorf("unexpected names: %v", st.Names)
+	}
+}
+
+func TestIRQSampler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interrupts")
+	if err := os.WriteFile(path, []byte(testInterrupts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newIRQSampler()
+	s.path = path
+	now := time.Now()
+	if v, err := s.Sample(now); err != nil || v != nil {
+		t.Fatalf("expected nil at first sample, got %v %v", v, err)
+	}
+	next := strings.Replace(testInterrupts, "1000          0", "4000          0", 1)
+	if err := os.WriteFile(path, []byte(next), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Sample(now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if v["irq24_eth0-TxRx-0"] != 3000 || v["irq25_eth0-TxRx-1"] != 0 {
+		t.Errorf("unexpected rates: %v", v)
+	}
+	if v["irq24_eth0-TxRx-0.cpu0"] != 3000 {
+		t.Errorf("unexpected per-IRQ per-cpu rates: %v", v)
+	}
+	if _, ok := v["irq24_eth0-TxRx-0.cpu1"]; ok {
+		t.Errorf("expected no pair without interrupts: %v", v)
+	}
+	if v[""] != 100 {
+		t.Errorf("expected concentration 100, got %v", v[""])
+	}
+}
+
+func TestIRQPeakMetrics(t *testing.T) {
+	points := []point{
+		{Usage: 50, Values: map[string]float64{"": 50, "irq24_eth0": 100, "irq24_eth0.cpu0": 50, "irq24_eth0.cpu1": 50}},
+		{Usage: 99, Values: map[string]float64{
+			"": 90, "irq1": 1, "irq2": 2, "irq3": 3, "irq4": 4, "irq5": 5, "irq24_eth0": 900, "irq9": 0,
+			"irq24_eth0.cpu0": 800, "irq24_eth0.cpu1": 100,
+			"irq1.cpu1": 1, "irq2.cpu0": 2, "irq3.cpu1": 3, "irq4.cpu1": 4, "irq5.cpu0": 5,
+		}},
+	}
+	s := newIRQSampler()
+	got := map[string]float64{}
+	for _, m := range s.PeakMetrics(points, 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	want := map[string]float64{
+		"irq_top_rate_at_peak.irq24_eth0":          900,
+		"irq_top_rate_at_peak.irq5":                5,
+		"irq_top_rate_at_peak.irq4":                4,
+		"irq_top_rate_at_peak.irq3":                3,
+		"irq_top_rate_at_peak.irq2":                2,
+		"irq_cpu_top_rate_at_peak.irq24_eth0.cpu0": 800,
+		"irq_cpu_top_rate_at_peak.irq24_eth0.cpu1": 100,
+		"irq_cpu_top_rate_at_peak.irq5.cpu0":       5,
+		"irq_cpu_top_rate_at_peak.irq4.cpu1":       4,
+		"irq_cpu_top_rate_at_peak.irq3.cpu1":       3,
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d metrics, got %v", len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+	if !s.Summarized("") || s.Summarized("irq24_eth0") || s.Summarized("irq24_eth0.cpu0") {
+		t.Error("expected only the concentration summarized")
+	}
+}
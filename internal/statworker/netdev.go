@@ -0,0 +1,110 @@
+package statworker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// netDevStat holds the counters of an interface in /proc/net/dev
+type netDevStat struct {
+	RxBytes   uint64
+	RxPackets uint64
+	TxBytes   uint64
+	TxPackets uint64
+}
+
+// readNetDev parses /proc/net/dev. The loopback interface is skipped.
+//
+//	Inter-|   Receive                                                |  Transmit
+//	 face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+//	  eth0: 4520863   41353    0    0    0     0          0         0  2317152   22004    0    0    0     0       0          0
+func readNetDev(r io.Reader) (map[string]*netDevStat, error) {
+	devs := map[string]*netDevStat{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		name, counters, ok := bytes.Cut(s.Bytes(), []byte(":"))
+		if !ok {
+			continue
+		}
+		iface := string(bytes.TrimSpace(name))
+		if iface == "lo" {
+			continue
+		}
+		sp := bytes.Fields(counters)
+		if len(sp) < 10 {
+			return nil, fmt.Errorf("unexpected fields of %s in /proc/net/dev", iface)
+		}
+		d := &netDevStat{}
+		for _, f := range []struct {
+			v   *uint64
+			idx int
+		}{{&d.RxBytes, 0}, {&d.RxPackets, 1}, {&d.TxBytes, 8}, {&d.TxPackets, 9}} {
+			v, err := parseUint(sp[f.idx])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", iface, err)
+			}
+			*f.v = v
+		}
+		devs[iface] = d
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scanner error: %w", err)
+	}
+	return devs, nil
+}
+
+type netDevSampler struct {
+	path     string
+	prev     map[string]*netDevStat
+	prevTime time.Time
+}
+
+func newNetDevSampler() *netDevSampler {
+	return &netDevSampler{path: "/proc/net/dev"}
+}
+
+func (s *netDevSampler) Group() string {
+	return "net"
+}
+
+// Sample reads /proc/net/dev and returns the bytes and packets per second
+// received and transmitted on each interface since the previous call, as
+// iface.rx_bytes, iface.tx_bytes, iface.rx_packets and iface.tx_packets.
+// It returns nil at the first call.
+func (s *netDevSampler) Sample(now time.Time) (map[string]float64, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	devs, err := readNetDev(f)
+	if err != nil {
+		return nil, err
+	}
+	prev, prevTime := s.prev, s.prevTime
+	s.prev, s.prevTime = devs, now
+	if prev == nil {
+		return nil, nil
+	}
+	elapsed := now.Sub(prevTime).Seconds()
+	if elapsed <= 0 {
+		return nil, nil
+	}
+	rates := map[string]float64{}
+	for iface, d := range devs {
+		p, ok := prev[iface]
+		if !ok {
+			continue
+		}
+		key := metricKey(iface)
+		rates[key+".rx_bytes"] = float64(gap(d.RxBytes, p.RxBytes)) / elapsed
+		rates[key+".tx_bytes"] = float64(gap(d.TxBytes, p.TxBytes)) / elapsed
+		rates[key+".rx_packets"] = float64(gap(d.RxPackets, p.RxPackets)) / elapsed
+		rates[key+".tx_packets"] = float64(gap(d.TxPackets, p.TxPackets)) / elapsed
+	}
+	return rates, nil
+}
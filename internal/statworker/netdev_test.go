@@ -0,0 +1,56 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0:10000     100    0    0    0     0          0         0    20000     200    0    0    0     0       0          0
+`
+
+func TestReadNetDev(t *testing.T) {
+	devs, err := readNetDev(strings.NewReader(testNetDev))
+	if err != nil {
+		t.Fatalf("readNetDev() error = %v", err)
+	}
+	if len(devs) != 1 {
+		t.Errorf("expected eth0 only, got %v", devs)
+	}
+	if d := devs["eth0"]; d == nil || d.RxBytes != 10000 || d.RxPackets != 100 || d.TxBytes != 20000 || d.TxPackets != 200 {
+		t.Errorf("unexpected eth0: %+v", d)
+	}
+	if _, err := readNetDev(strings.NewReader("eth0: 1 2 3\n")); err == nil {
+		t.Error("expected error for short line")
+	}
+}
+
+func TestNetDevSampler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dev")
+	if err := os.WriteFile(path, []byte(testNetDev), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newNetDevSampler()
+	s.path = path
+	now := time.Now()
+	if v, err := s.Sample(now); err != nil || v != nil {
+		t.Fatalf("expected nil at first sample, got %v %v", v, err)
+	}
+
+	next := strings.Replace(testNetDev, "eth0:10000     100", "eth0:30000     140", 1)
+	if err := os.WriteFile(path, []byte(next), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Sample(now.Add(2 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v["eth0.rx_bytes"] != 10000 || v["eth0.rx_packets"] != 20 || v["eth0.tx_bytes"] != 0 || v["eth0.tx_packets"] != 0 {
+		t.Errorf("unexpected eth0 rate: %v", v)
+	}
+}
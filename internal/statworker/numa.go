@@ -0,0 +1,84 @@
+package statworker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// nodeSysfs is the sysfs directory of NUMA nodes
+var nodeSysfs = "/sys/devices/system/node"
+
+type numaNode struct {
+	ID   int
+	CPUs []int
+}
+
+// readNUMANodes reads the cpulist of each NUMA node. Memory only nodes are
+// skipped.
+func readNUMANodes(dir string) ([]*numaNode, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*numaNode
+	for _, e := range entries {
+		name, ok := strings.CutPrefix(e.Name(), "node")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		cpus, err := readCPUList(filepath.Join(dir, e.Name(), "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+		if len(cpus) == 0 {
+			continue
+		}
+		nodes = append(nodes, &numaNode{ID: id, CPUs: cpus})
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no NUMA nodes with cpus found in %s", dir)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// cpuSetUsage returns the usage of a set of cpus in a sample, calculated
+// from the sum of their gaps. ok is false when none of them has a gap.
+func cpuSetUsage(cpus []int, u *cpuUsage) (float64, bool) {
+	var busy, total uint64
+	for _, cpu := range cpus {
+		if cu, ok := u.CPUs[cpu]; ok {
+			busy += cu.Busy
+			total += cu.Total
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(busy) / float64(total) * 100.0, true
+}
+
+// numaMetrics summarizes the usage of each NUMA node in the samples.
+func numaMetrics(nodes []*numaNode, samples []*cpuUsage, epoch int64) []*maxcpu.Metric {
+	var res []*maxcpu.Metric
+	for _, n := range nodes {
+		var usages []float64
+		for _, u := range samples {
+			if usage, ok := cpuSetUsage(n.CPUs, u); ok {
+				usages = append(usages, usage)
+			}
+		}
+		res = append(res, summarize(fmt.Sprintf("numa_node_usage.node%d", n.ID), usages, epoch)...)
+	}
+	return res
+}
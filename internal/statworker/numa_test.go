@@ -0,0 +1,62 @@
+package statworker
+
+import (
+	"testing"
+)
+
+func TestReadNUMANodes(t *testing.T) {
+	root := writeSysfs(t, map[string]string{
+		"node1/cpulist": "4-7\n",
+		"node0/cpulist": "0-3\n",
+		"node2/cpulist": "\n", // memory only
+		"online":        "0-2\n",
+		"has_cpu":       "0-1\n",
+	})
+	nodes, err := readNUMANodes(root)
+	if err != nil {
+		t.Fatalf("readNUMANodes() error = %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(nodes))
+	}
+	if nodes[0].ID != 0 || len(nodes[0].CPUs) != 4 || nodes[1].ID != 1 || nodes[1].CPUs[0] != 4 {
+		t.Errorf("unexpected nodes: %+v %+v", nodes[0], nodes[1])
+	}
+}
+
+func TestNUMAMetrics(t *testing.T) {
+	nodes := []*numaNode{
+		{ID: 0, CPUs: []int{0, 1}},
+		{ID: 1, CPUs: []int{2, 3}},
+	}
+	sample := func(busy map[int]uint64) *cpuUsage {
+		u := &cpuUsage{CPUs: map[int]*cpuUsage{}}
+		for cpu, b := range busy {
+			u.CPUs[cpu] = &cpuUsage{Busy: b, Total: 100}
+		}
+		return u
+	}
+	samples := []*cpuUsage{
+		sample(map[int]uint64{0: 100, 1: 100, 2: 0, 3: 10}),
+		sample(map[int]uint64{0: 100, 1: 50, 2: 0, 3: 0}),
+	}
+	got := map[string]float64{}
+	for _, m := range numaMetrics(nodes, samples, 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	want := map[string]float64{
+		"numa_node_usage.node0.max": 100,
+		"numa_node_usage.node0.min": 75,
+		"numa_node_usage.node0.avg": 87.5,
+		"numa_node_usage.node1.max": 5,
+		"numa_node_usage.node1.min": 0,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+	if len(got) != 10 {
+		t.Errorf("expected 10 metrics, got %d", len(got))
+	}
+}
@@ -0,0 +1,105 @@
+package statworker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// powercapSysfs is the sysfs directory of powercap
+var powercapSysfs = "/sys/class/powercap"
+
+type raplZone struct {
+	Name string
+	Dir  string
+	// MaxEnergy is max_energy_range_uj, where energy_uj wraps around
+	MaxEnergy uint64
+}
+
+// findRAPLZones finds the package zones intel-rapl:N. Their subzones such as
+// intel-rapl:0:0 for cores are skipped.
+func findRAPLZones(dir string) ([]*raplZone, error) {
+	dirs, err := filepath.Glob(filepath.Join(dir, "intel-rapl:*"))
+	if err != nil {
+		return nil, err
+	}
+	var zones []*raplZone
+	for _, d := range dirs {
+		if strings.Count(filepath.Base(d), ":") != 1 {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(d, "name"))
+		if err != nil {
+			return nil, err
+		}
+		maxEnergy, err := readUint(filepath.Join(d, "max_energy_range_uj"))
+		if err != nil {
+			return nil, err
+		}
+		// energy_uj is only readable by root on recent kernels
+		if _, err := readUint(filepath.Join(d, "energy_uj")); err != nil {
+			return nil, err
+		}
+		zones = append(zones, &raplZone{
+			Name:      metricKey(strings.TrimSpace(string(b))),
+			Dir:       d,
+			MaxEnergy: maxEnergy,
+		})
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("no RAPL zones found in %s", dir)
+	}
+	return zones, nil
+}
+
+type raplSampler struct {
+	zones    []*raplZone
+	prev     []uint64
+	prevTime time.Time
+}
+
+func newRAPLSampler(dir string) (*raplSampler, error) {
+	zones, err := findRAPLZones(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &raplSampler{zones: zones}, nil
+}
+
+func (s *raplSampler) Group() string {
+	return "rapl_power_watts"
+}
+
+// Sample reads energy_uj of the zones and returns the power in watts keyed
+// by zone name since the previous call. It returns nil at the first call.
+func (s *raplSampler) Sample(now time.Time) (map[string]float64, error) {
+	energies := make([]uint64, len(s.zones))
+	for i, z := range s.zones {
+		e, err := readUint(filepath.Join(z.Dir, "energy_uj"))
+		if err != nil {
+			return nil, err
+		}
+		energies[i] = e
+	}
+	prev, prevTime := s.prev, s.prevTime
+	s.prev, s.prevTime = energies, now
+	if prev == nil {
+		return nil, nil
+	}
+	elapsed := now.Sub(prevTime).Seconds()
+	if elapsed <= 0 {
+		return nil, nil
+	}
+	watts := map[string]float64{}
+	for i, z := range s.zones {
+		d := energies[i] - prev[i]
+		if energies[i] < prev[i] {
+			// wrapped around
+			d = z.MaxEnergy - prev[i] + energies[i]
+		}
+		watts[z.Name] = float64(d) / 1e6 / elapsed
+	}
+	return watts, nil
+}
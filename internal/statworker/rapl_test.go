@@ -0,0 +1,53 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRAPLSampler(t *testing.T) {
+	root := writeSysfs(t, map[string]string{
+		"intel-rapl:0/name":                  "package-0\n",
+		"intel-rapl:0/energy_uj":             "1000000\n",
+		"intel-rapl:0/max_energy_range_uj":   "262143328850\n",
+		"intel-rapl:0:0/name":                "core\n",
+		"intel-rapl:0:0/energy_uj":           "0\n",
+		"intel-rapl:0:0/max_energy_range_uj": "262143328850\n",
+		"intel-rapl:1/name":                  "package-1\n",
+		"intel-rapl:1/energy_uj":             "262140000000\n",
+		"intel-rapl:1/max_energy_range_uj":   "262143328850\n",
+	})
+	s, err := newRAPLSampler(root)
+	if err != nil {
+		t.Fatalf("newRAPLSampler() error = %v", err)
+	}
+	if len(s.zones) != 2 {
+		t.Fatalf("expected 2 package zones, got %d", len(s.zones))
+	}
+	now := time.Now()
+	if v, err := s.Sample(now); err != nil || v != nil {
+		t.Fatalf("expected nil at first sample, got %v %v", v, err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "intel-rapl:0", "energy_uj"), []byte("201000000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 3328850 to wrap around and 196671150 after it
+	if err := os.WriteFile(filepath.Join(root, "intel-rapl:1", "energy_uj"), []byte("196671150\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Sample(now.Add(2 * time.Second))
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if v["package-0"] != 100 || v["package-1"] != 100 {
+		t.Errorf("unexpected power: %v", v)
+	}
+}
+
+func TestRAPLSampler_NoZones(t *testing.T) {
+	if _, err := newRAPLSampler(t.TempDir()); err == nil {
+		t.Error("expected error without RAPL zones")
+	}
+}
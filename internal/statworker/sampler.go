@@ -0,0 +1,189 @@
+package statworker
+
+import (
+	"log"
+	"time"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// Sampler is a source of values the Worker samples every second. The values
+// of each named series are kept in a ring buffer and reported as max, min,
+// avg, 90 and 75 percentile, so a new source only has to read its counters.
+type Sampler interface {
+	// Group is the graph name of the series. The series named "" is
+	// reported as the group itself, and the others as group.name.
+	Group() string
+	// Sample reads the raw counters and returns the per interval values of
+	// each series computed from the previous call. It returns nil at the
+	// first call.
+	Sample(now time.Time) (map[string]float64, error)
+}
+
+// peakSampler is a Sampler also reporting from the values of each second
+// along with the cpu usage of the second, such as the values at the second
+// the cpu usage peaked.
+type peakSampler interface {
+	Sampler
+	// Summarized reports whether a series is summarized. The others are
+	// only given to PeakMetrics, such as breakdowns too many to report.
+	Summarized(series string) bool
+	// PeakMetrics reports the metrics of the values of each second.
+	PeakMetrics(points []point, epoch int64) []*maxcpu.Metric
+}
+
+// point is the values of the series of a sampler at a second, with the cpu
+// usage of the second.
+type point struct {
+	Usage  float64
+	Values map[string]float64
+}
+
+// peakPoint returns the point taken at the highest cpu usage.
+func peakPoint(points []point) (point, bool) {
+	var peak point
+	for _, p := range points {
+		if peak.Values == nil || p.Usage > peak.Usage {
+			peak = p
+		}
+	}
+	return peak, peak.Values != nil
+}
+
+// seriesRing keeps the values of a series by the sequence number of the
+// sample, as many as the samples in the ring buffer of the Worker.
+type seriesRing struct {
+	values []float64
+	seqs   []uint64
+	// last is the sequence number of the latest value
+	last uint64
+}
+
+func newSeriesRing() *seriesRing {
+	return &seriesRing{
+		values: make([]float64, historySize-1),
+		seqs:   make([]uint64, historySize-1),
+	}
+}
+
+func (r *seriesRing) push(seq uint64, v float64) {
+	i := seq % uint64(len(r.values))
+	r.values[i] = v
+	r.seqs[i] = seq
+	r.last = seq
+}
+
+// get returns the value of the sample seq, false when it was not sampled or
+// has been overwritten.
+func (r *seriesRing) get(seq uint64) (float64, bool) {
+	i := seq % uint64(len(r.values))
+	if r.seqs[i] != seq {
+		return 0, false
+	}
+	return r.values[i], true
+}
+
+// samplerSeries holds the ring buffers of the series of a sampler.
+type samplerSeries struct {
+	Sampler
+	series map[string]*seriesRing
+}
+
+// push stores the values of the sample seq. The series without a value
+// retained, such as of a device removed, are dropped.
+func (s *samplerSeries) push(seq uint64, values map[string]float64) {
+	for name, v := range values {
+		r, ok := s.series[name]
+		if !ok {
+			r = newSeriesRing()
+			s.series[name] = r
+		}
+		r.push(seq, v)
+	}
+	for name, r := range s.series {
+		if seq-r.last >= historySize-1 {
+			delete(s.series, name)
+		}
+	}
+}
+
+// values returns the values of a series in the samples.
+func (s *samplerSeries) values(name string, samples []*cpuUsage) []float64 {
+	var values []float64
+	for _, u := range samples {
+		if v, ok := s.series[name].get(u.Seq); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// points returns the values of all the series at each of the samples with
+// a value.
+func (s *samplerSeries) points(samples []*cpuUsage) []point {
+	var points []point
+	for _, u := range samples {
+		values := map[string]float64{}
+		for name, r := range s.series {
+			if v, ok := r.get(u.Seq); ok {
+				values[name] = v
+			}
+		}
+		if len(values) > 0 {
+			points = append(points, point{Usage: u.Usage, Values: values})
+		}
+	}
+	return points
+}
+
+// addSampler registers a sampler to be sampled every second.
+func (w *Worker) addSampler(s Sampler) {
+	w.samplers = append(w.samplers, &samplerSeries{Sampler: s, series: map[string]*seriesRing{}})
+}
+
+// tick samples each sampler, /proc/stat first, and stores the sample with
+// the values of the series. A sampler failing to read is skipped for the
+// tick. The error of /proc/stat, which the other values are stored along
+// with, is returned without sampling the rest.
+func (w *Worker) tick(now time.Time) error {
+	values := make([]map[string]float64, len(w.samplers))
+	for i, s := range w.samplers {
+		v, err := s.Sample(now)
+		if err != nil {
+			if i == 0 {
+				return err
+			}
+			log.Printf("%v", err)
+			continue
+		}
+		values[i] = v
+	}
+	w.store(values)
+	return nil
+}
+
+// samplerMetrics reports the summary of each series of the samplers in the
+// samples, and the metrics of the values of each second of peakSamplers.
+// The caller must hold the lock.
+func (w *Worker) samplerMetrics(samples []*cpuUsage, epoch int64) []*maxcpu.Metric {
+	var res []*maxcpu.Metric
+	for _, s := range w.samplers {
+		ps, isPeak := s.Sampler.(peakSampler)
+		for _, name := range sortedKeys(s.series) {
+			if isPeak && !ps.Summarized(name) {
+				continue
+			}
+			group := s.Group()
+			if name != "" {
+				group += "." + name
+			}
+			res = append(res, summarize(group, s.values(name, samples), epoch)...)
+		}
+		if isPeak {
+			if points := s.points(samples); len(points) > 0 {
+				res = append(res, ps.PeakMetrics(points, epoch)...)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,146 @@
+package statworker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+type testSampler struct {
+	group  string
+	values []map[string]float64
+	err    error
+}
+
+func (s *testSampler) Group() string {
+	return s.group
+}
+
+func (s *testSampler) Sample(time.Time) (map[string]float64, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if len(s.values) == 0 {
+		return nil, nil
+	}
+	v := s.values[0]
+	s.values = s.values[1:]
+	return v, nil
+}
+
+// testPeakSampler does not summarize the series "hidden".
+type testPeakSampler struct {
+	testSampler
+	// points are the points given to PeakMetrics
+	points []point
+}
+
+func (s *testPeakSampler) Summarized(series string) bool {
+	return series != "hidden"
+}
+
+func (s *testPeakSampler) PeakMetrics(points []point, epoch int64) []*maxcpu.Metric {
+	s.points = points
+	return []*maxcpu.Metric{{Group: "test", Key: "points", Metric: float64(len(points)), Epoch: epoch}}
+}
+
+func TestSamplerMetrics(t *testing.T) {
+	w := newWorker(Config{})
+	gauge := &testSampler{group: "gauge"}
+	peak := &testPeakSampler{testSampler: testSampler{group: "peak"}}
+	w.addSampler(gauge)
+	w.addSampler(peak)
+	sample := func(cpu *cpuStat, values ...map[string]float64) {
+		w.store(append([]map[string]float64{w.procStat.add(cpu)}, values...))
+	}
+
+	sample(&cpuStat{})
+	// busier every second
+	sample(&cpuStat{User: 10, Idle: 90}, map[string]float64{"": 10}, map[string]float64{"a": 1, "hidden": 5})
+	sample(&cpuStat{User: 40, Idle: 160}, nil, map[string]float64{"a": 2, "hidden": 6})
+	sample(&cpuStat{User: 90, Idle: 210}, map[string]float64{"": 30}, map[string]float64{"a": 3})
+
+	got := map[string]float64{}
+	for _, m := range w.samplerMetrics(w.samplesSince(0), 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	want := map[string]float64{
+		usageGroup + ".max": 50,
+		usageGroup + ".min": 10,
+		"gauge.max":         30,
+		"gauge.min":         10,
+		"gauge.avg":         20,
+		"peak.a.max":        3,
+		"peak.a.avg":        2,
+		"test.points":       3,
+	}
+	if len(got) != 16 {
+		t.Errorf("expected the summary of 3 series and the peak metrics, got %v", got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+	if _, ok := got["peak.hidden.max"]; ok {
+		t.Error("expected the series not summarized to be left out")
+	}
+	if p, ok := peakPoint(peak.points); !ok || p.Usage != 50 || len(p.Values) != 1 || p.Values["a"] != 3 {
+		t.Errorf("expected the point of the last sample at the peak, got %+v", p)
+	}
+	if peak.points[0].Values["hidden"] != 5 {
+		t.Errorf("expected the series not summarized in the points, got %+v", peak.points[0])
+	}
+
+	got = map[string]float64{}
+	for _, m := range w.samplerMetrics(w.samplesSince(2), 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	if got["gauge.max"] != 30 || got["gauge.min"] != 30 || got["test.points"] != 1 {
+		t.Errorf("unexpected metrics since 2: %v", got)
+	}
+}
+
+func TestSamplerSeries_Expire(t *testing.T) {
+	s := &samplerSeries{Sampler: &testSampler{}, series: map[string]*seriesRing{}}
+	s.push(1, map[string]float64{"gone": 1, "kept": 1})
+	s.push(historySize-1, map[string]float64{"kept": 2})
+	if _, ok := s.series["gone"]; !ok {
+		t.Fatal("expected the series retained while its value is in the ring")
+	}
+	s.push(historySize, map[string]float64{"kept": 3})
+	if _, ok := s.series["gone"]; ok {
+		t.Error("expected the series without a value retained to be dropped")
+	}
+	if _, ok := s.series["kept"].get(1); ok {
+		t.Error("expected the value overwritten")
+	}
+	if v, ok := s.series["kept"].get(historySize); !ok || v != 3 {
+		t.Errorf("expected the latest value, got %v %v", v, ok)
+	}
+}
+
+func TestTick(t *testing.T) {
+	w := newWorker(Config{})
+	failing := &testSampler{group: "failing", err: fmt.Errorf("failed")}
+	gauge := &testSampler{group: "gauge", values: []map[string]float64{{"": 1}, {"": 2}}}
+	w.addSampler(failing)
+	w.addSampler(gauge)
+	now := time.Now()
+	for i := range 2 {
+		if err := w.tick(now.Add(time.Duration(i) * time.Second)); err != nil {
+			t.Fatalf("tick() error = %v", err)
+		}
+	}
+	if w.seq != 1 {
+		t.Fatalf("expected a sample after the baseline, got seq %d", w.seq)
+	}
+	if len(w.samplers[1].series) != 0 {
+		t.Errorf("expected no series of the failing sampler, got %v", w.samplers[1].series)
+	}
+	if v := w.samplers[2].values("", w.samplesSince(0)); len(v) != 1 || v[0] != 2 {
+		t.Errorf("expected the value sampled along with the sample, got %v", v)
+	}
+}
@@ -0,0 +1,117 @@
+package statworker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// schedstatRunDelayField is the position of run_delay, the cumulative time
+// tasks spent waiting on the run queue in nanoseconds, in a cpuN line.
+// See Documentation/scheduler/sched-stats.rst
+const schedstatRunDelayField = 8
+
+// readSchedstat returns run_delay of each cpu in /proc/schedstat.
+//
+//	version 15
+//	timestamp 4295892948
+//	cpu0 0 0 1048224 377306 621476 442547 99412046946 10622366710 671203
+//	domain0 00000003 ...
+func readSchedstat(r io.Reader) (map[int]uint64, error) {
+	delays := map[int]uint64{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := s.Bytes()
+		if !bytes.HasPrefix(l, cpuLineHeader[:3]) {
+			continue
+		}
+		sp := bytes.Fields(l)
+		if len(sp) <= schedstatRunDelayField {
+			return nil, fmt.Errorf("unexpected cpu line in /proc/schedstat: %q", l)
+		}
+		id, err := strconv.Atoi(string(sp[0][3:]))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected cpu line %q: %w", sp[0], err)
+		}
+		d, err := parseUint(sp[schedstatRunDelayField])
+		if err != nil {
+			return nil, err
+		}
+		delays[id] = d
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scanner error: %w", err)
+	}
+	if len(delays) == 0 {
+		return nil, fmt.Errorf("no cpu stats found in /proc/schedstat")
+	}
+	return delays, nil
+}
+
+type schedstatSampler struct {
+	path     string
+	perCPU   bool
+	prev     map[int]uint64
+	prevTime time.Time
+}
+
+// newSchedstatSampler checks that /proc/schedstat is readable, which needs
+// CONFIG_SCHEDSTATS.
+func newSchedstatSampler(perCPU bool) (*schedstatSampler, error) {
+	s := &schedstatSampler{path: "/proc/schedstat", perCPU: perCPU}
+	if _, err := s.read(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *schedstatSampler) read() (map[int]uint64, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readSchedstat(f)
+}
+
+func (s *schedstatSampler) Group() string {
+	return "run_delay_ms"
+}
+
+// Sample reads /proc/schedstat and returns the waiting time on the run
+// queue of all cpus in milliseconds per second since the previous call,
+// and of each cpu as cpuN with perCPU. It returns nil at the first call.
+func (s *schedstatSampler) Sample(now time.Time) (map[string]float64, error) {
+	delays, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	prev, prevTime := s.prev, s.prevTime
+	s.prev, s.prevTime = delays, now
+	if prev == nil {
+		return nil, nil
+	}
+	elapsed := now.Sub(prevTime).Seconds()
+	if elapsed <= 0 {
+		return nil, nil
+	}
+	var total float64
+	res := map[string]float64{}
+	for cpu, d := range delays {
+		p, ok := prev[cpu]
+		if !ok {
+			continue
+		}
+		ms := float64(gap(d, p)) / float64(time.Millisecond) / elapsed
+		total += ms
+		if s.perCPU {
+			res[fmt.Sprintf("cpu%d", cpu)] = ms
+		}
+	}
+	res[""] = total
+	return res, nil
+}
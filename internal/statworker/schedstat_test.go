@@ -0,0 +1,58 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSchedstat = `version 15
+timestamp 4295892948
+cpu0 0 0 1048224 377306 621476 442547 99412046946 10000000000 671203
+domain0 00000003 1 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+cpu1 0 0 1048224 377306 621476 442547 99412046946 20000000000 671203
+domain0 00000003 1 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+`
+
+func TestReadSchedstat(t *testing.T) {
+	delays, err := readSchedstat(strings.NewReader(testSchedstat))
+	if err != nil {
+		t.Fatalf("readSchedstat() error = %v", err)
+	}
+	if len(delays) != 2 || delays[0] != 10000000000 || delays[1] != 20000000000 {
+		t.Errorf("unexpected run delays: %v", delays)
+	}
+	if _, err := readSchedstat(strings.NewReader("version 15\ncpu0 0 0 1\n")); err == nil {
+		t.Error("expected error for short cpu line")
+	}
+	if _, err := readSchedstat(strings.NewReader("version 15\n")); err == nil {
+		t.Error("expected error without cpu lines")
+	}
+}
+
+func TestSchedstatSampler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedstat")
+	if err := os.WriteFile(path, []byte(testSchedstat), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := &schedstatSampler{path: path, perCPU: true}
+	now := time.Now()
+	if v, err := s.Sample(now); err != nil || v != nil {
+		t.Fatalf("expected nil at first sample, got %v %v", v, err)
+	}
+	// cpu0 waited 500ms, cpu1 250ms in 500ms
+	next := strings.Replace(testSchedstat, "10000000000", "10500000000", 1)
+	next = strings.Replace(next, "20000000000", "20250000000", 1)
+	if err := os.WriteFile(path, []byte(next), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Sample(now.Add(500 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if v["cpu0"] != 1000 || v["cpu1"] != 500 || v[""] != 1500 {
+		t.Errorf("unexpected run delays: %v", v)
+	}
+}
@@ -0,0 +1,63 @@
+package statworker
+
+import (
+	"math"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// coreSkew returns how unevenly the load is spread over the cpus in a
+// sample: the usage of the busiest cpu minus the mean, and the coefficient
+// of variation of the usages. ok is false when there are no cpus to compare.
+func coreSkew(u *cpuUsage) (hotMinusMean float64, cv float64, ok bool) {
+	var usages []float64
+	for _, cu := range u.CPUs {
+		if cu.Total > 0 {
+			usages = append(usages, cu.Usage)
+		}
+	}
+	if len(usages) == 0 {
+		return 0, 0, false
+	}
+	var total, hot float64
+	for _, usage := range usages {
+		total += usage
+		hot = max(hot, usage)
+	}
+	mean := total / float64(len(usages))
+	var variance float64
+	for _, usage := range usages {
+		variance += (usage - mean) * (usage - mean)
+	}
+	variance /= float64(len(usages))
+	if mean > 0 {
+		cv = math.Sqrt(variance) / mean
+	}
+	return hot - mean, cv, true
+}
+
+// skewMetrics reports max and avg of the core skew in the samples.
+func skewMetrics(samples []*cpuUsage, epoch int64) []*maxcpu.Metric {
+	var n int
+	var hotMax, hotTotal, cvMax, cvTotal float64
+	for _, u := range samples {
+		hot, cv, ok := coreSkew(u)
+		if !ok {
+			continue
+		}
+		n++
+		hotMax = max(hotMax, hot)
+		hotTotal += hot
+		cvMax = max(cvMax, cv)
+		cvTotal += cv
+	}
+	if n == 0 {
+		return nil
+	}
+	return []*maxcpu.Metric{
+		{Group: "core_skew_hot_minus_mean", Key: "max", Metric: hotMax, Epoch: epoch},
+		{Group: "core_skew_hot_minus_mean", Key: "avg", Metric: hotTotal / float64(n), Epoch: epoch},
+		{Group: "core_skew_cv", Key: "max", Metric: cvMax, Epoch: epoch},
+		{Group: "core_skew_cv", Key: "avg", Metric: cvTotal / float64(n), Epoch: epoch},
+	}
+}
@@ -0,0 +1,62 @@
+package statworker
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCoreSkew(t *testing.T) {
+	hot, cv, ok := coreSkew(perCPUSample(map[int]float64{0: 100, 1: 0, 2: 0, 3: 0}))
+	if !ok {
+		t.Fatal("expected ok")
+	}
+	if hot != 75 {
+		t.Errorf("expected hot minus mean 75, got %v", hot)
+	}
+	// stddev 43.30 / mean 25
+	if math.Abs(cv-math.Sqrt(3)) > 1e-9 {
+		t.Errorf("expected cv %v, got %v", math.Sqrt(3), cv)
+	}
+
+	hot, cv, ok = coreSkew(perCPUSample(map[int]float64{0: 50, 1: 50}))
+	if !ok || hot != 0 || cv != 0 {
+		t.Errorf("expected no skew for even load, got %v %v %v", hot, cv, ok)
+	}
+
+	hot, cv, ok = coreSkew(perCPUSample(map[int]float64{0: 0, 1: 0}))
+	if !ok || hot != 0 || cv != 0 {
+		t.Errorf("expected no skew for idle cpus, got %v %v %v", hot, cv, ok)
+	}
+
+	if _, _, ok = coreSkew(&cpuUsage{}); ok {
+		t.Error("expected not ok without per-cpu samples")
+	}
+}
+
+func TestSkewMetrics(t *testing.T) {
+	samples := []*cpuUsage{
+		perCPUSample(map[int]float64{0: 100, 1: 0}),
+		perCPUSample(map[int]float64{0: 50, 1: 50}),
+	}
+	got := map[string]float64{}
+	for _, m := range skewMetrics(samples, 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	want := map[string]float64{
+		"core_skew_hot_minus_mean.max": 50,
+		"core_skew_hot_minus_mean.avg": 25,
+		"core_skew_cv.max":             1,
+		"core_skew_cv.avg":             0.5,
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d metrics, got %v", len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+	if res := skewMetrics(nil, 1); res != nil {
+		t.Errorf("expected no metrics without samples, got %v", res)
+	}
+}
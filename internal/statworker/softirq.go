@@ -0,0 +1,173 @@
+package statworker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// softirqStat holds the cumulative counters of /proc/softirqs. Counts are
+// keyed by lower cased type, in the order of CPUs.
+type softirqStat struct {
+	CPUs   []int
+	Counts map[string][]uint64
+}
+
+// readSoftirqs parses /proc/softirqs
+//
+//	             CPU0       CPU1
+//	   HI:          0          0
+//	TIMER:      20081      18342
+func readSoftirqs(r io.Reader) (*softirqStat, error) {
+	s := bufio.NewScanner(r)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, fmt.Errorf("scanner error: %w", err)
+		}
+		return nil, fmt.Errorf("no header found in /proc/softirqs")
+	}
+	cpus, err := parseCPUHeader(s.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	st := &softirqStat{CPUs: cpus, Counts: map[string][]uint64{}}
+	for s.Scan() {
+		sp := bytes.Fields(s.Bytes())
+		if len(sp) < 2 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSuffix(string(sp[0]), ":"))
+		counts := make([]uint64, 0, len(cpus))
+		for _, b := range sp[1:] {
+			if len(counts) == len(cpus) {
+				break
+			}
+			c, err := parseUint(b)
+			if err != nil {
+				return nil, err
+			}
+			counts = append(counts, c)
+		}
+		st.Counts[name] = counts
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scanner error: %w", err)
+	}
+	return st, nil
+}
+
+// parseCPUHeader parses the "CPU0 CPU1 ..." header line of /proc/softirqs
+// and /proc/interrupts.
+func parseCPUHeader(l []byte) ([]int, error) {
+	var cpus []int
+	for _, b := range bytes.Fields(l) {
+		id, err := strconv.Atoi(strings.TrimPrefix(string(b), "CPU"))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected cpu header %q: %w", b, err)
+		}
+		cpus = append(cpus, id)
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no cpus found in header")
+	}
+	return cpus, nil
+}
+
+type softirqSampler struct {
+	path     string
+	perCPU   bool
+	prev     *softirqStat
+	prevTime time.Time
+}
+
+func newSoftirqSampler(perCPU bool) *softirqSampler {
+	return &softirqSampler{path: "/proc/softirqs", perCPU: perCPU}
+}
+
+func (s *softirqSampler) Group() string {
+	return "softirq_rate"
+}
+
+// Sample reads /proc/softirqs and returns the rate per second of each type
+// on all cpus since the previous call, and of each type and cpu as
+// type.cpuN with perCPU. It returns nil at the first call.
+func (s *softirqSampler) Sample(now time.Time) (map[string]float64, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := readSoftirqs(f)
+	if err != nil {
+		return nil, err
+	}
+	prev, prevTime := s.prev, s.prevTime
+	s.prev, s.prevTime = st, now
+	if prev == nil {
+		return nil, nil
+	}
+	elapsed := now.Sub(prevTime).Seconds()
+	if elapsed <= 0 {
+		return nil, nil
+	}
+	return s.rates(prev, st, elapsed), nil
+}
+
+func (s *softirqSampler) rates(prev, cur *softirqStat, elapsed float64) map[string]float64 {
+	rates := map[string]float64{}
+	for name, counts := range cur.Counts {
+		p := prev.Counts[name]
+		var total uint64
+		for i, c := range counts {
+			var d uint64
+			if i < len(p) {
+				d = gap(c, p[i])
+			}
+			total += d
+			if s.perCPU {
+				rates[fmt.Sprintf("%s.cpu%d", name, cur.CPUs[i])] = float64(d) / elapsed
+			}
+		}
+		rates[name] = float64(total) / elapsed
+	}
+	return rates
+}
+
+func (s *softirqSampler) Summarized(string) bool {
+	return true
+}
+
+// PeakMetrics reports the type with the highest rate at the second the cpu
+// usage peaked.
+func (s *softirqSampler) PeakMetrics(points []point, epoch int64) []*maxcpu.Metric {
+	p, ok := peakPoint(points)
+	if !ok {
+		return nil
+	}
+	dominant := ""
+	for _, name := range sortedKeys(p.Values) {
+		if strings.Contains(name, ".") {
+			// per cpu
+			continue
+		}
+		if dominant == "" || p.Values[name] > p.Values[dominant] {
+			dominant = name
+		}
+	}
+	if dominant == "" {
+		return nil
+	}
+	return []*maxcpu.Metric{{
+		Group:  "softirq_dominant_at_peak",
+		Key:    dominant,
+		Metric: p.Values[dominant],
+		Epoch:  epoch,
+	}}
+}
@@ -0,0 +1,84 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSoftirqs = `                    CPU0       CPU2
+          HI:          0          1
+       TIMER:       1000       2000
+      NET_RX:        100         10
+         RCU:         50         50
+`
+
+func TestReadSoftirqs(t *testing.T) {
+	st, err := readSoftirqs(strings.NewReader(testSoftirqs))
+	if err != nil {
+		t.Fatalf("readSoftirqs() error = %v", err)
+	}
+	if len(st.CPUs) != 2 || st.CPUs[0] != 0 || st.CPUs[1] != 2 {
+		t.Errorf("unexpected cpus: %v", st.CPUs)
+	}
+	if len(st.Counts) != 4 {
+		t.Errorf("expected 4 types, got %v", st.Counts)
+	}
+	if c := st.Counts["net_rx"]; len(c) != 2 || c[0] != 100 || c[1] != 10 {
+		t.Errorf("unexpected net_rx counts: %v", c)
+	}
+}
+
+func TestReadSoftirqs_Invalid(t *testing.T) {
+	if _, err := readSoftirqs(strings.NewReader("")); err == nil {
+		t.Error("expected error for empty input")
+	}
+	if _, err := readSoftirqs(strings.NewReader("CPU0\nHI: x\n")); err == nil {
+		t.Error("expected error for invalid counter")
+	}
+}
+
+func TestSoftirqSampler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "softirqs")
+	if err := os.WriteFile(path, []byte(testSoftirqs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newSoftirqSampler(true)
+	s.path = path
+	now := time.Now()
+	if v, err := s.Sample(now); err != nil || v != nil {
+		t.Fatalf("expected nil at first sample, got %v %v", v, err)
+	}
+
+	next := strings.ReplaceAll(testSoftirqs, "100         10", "300         30")
+	if err := os.WriteFile(path, []byte(next), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Sample(now.Add(2 * time.Second))
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	if v["net_rx"] != 110 || v["timer"] != 0 {
+		t.Errorf("unexpected total rates: %v", v)
+	}
+	if v["net_rx.cpu0"] != 100 || v["net_rx.cpu2"] != 10 {
+		t.Errorf("unexpected per-cpu rates: %v", v)
+	}
+}
+
+func TestSoftirqPeakMetrics(t *testing.T) {
+	points := []point{
+		{Usage: 90, Values: map[string]float64{"net_rx": 500, "timer": 1000}},
+		{Usage: 95, Values: map[string]float64{"net_rx": 3000, "timer": 800, "net_rx.cpu0": 2900, "net_rx.cpu1": 100}},
+	}
+	s := newSoftirqSampler(true)
+	res := s.PeakMetrics(points, 1)
+	if len(res) != 1 || res[0].Group != "softirq_dominant_at_peak" || res[0].Key != "net_rx" || res[0].Metric != 3000 {
+		t.Errorf("unexpected metrics: %v", res)
+	}
+	if res := s.PeakMetrics(nil, 1); res != nil {
+		t.Errorf("expected no metrics without softirq samples, got %v", res)
+	}
+}
@@ -0,0 +1,135 @@
+package statworker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// sourceEntry is a line of the sources config file, a kind followed by
+// key=value parameters.
+type sourceEntry struct {
+	Kind   string
+	Params map[string]string
+	Line   int
+}
+
+// readSourceConfig reads the sources config file and returns the samplers
+// of the entries.
+func readSourceConfig(path string) ([]Sampler, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := parseSourceConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var samplers []Sampler
+	names := map[string]bool{}
+	for _, e := range entries {
+		s, err := newEntrySampler(e)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, e.Line, err)
+		}
+		if names[s.Group()] {
+			return nil, fmt.Errorf("%s:%d: duplicated name %q", path, e.Line, s.Group())
+		}
+		names[s.Group()] = true
+		samplers = append(samplers, s)
+	}
+	return samplers, nil
+}
+
+// newEntrySampler returns the sampler of an entry.
+func newEntrySampler(e *sourceEntry) (Sampler, error) {
+	switch e.Kind {
+	case "gauge", "counter":
+		return newFileSampler(e.Kind == "counter", e.Params)
+	case "command":
+		return newCommandSampler(e.Params)
+	}
+	return nil, fmt.Errorf("unknown kind %q", e.Kind)
+}
+
+// parseSourceConfig parses the sources config. Empty lines and lines
+// starting with # are ignored.
+//
+//	# kind key=value ...
+//	gauge name=nvme_temp path=/sys/class/hwmon/hwmon2/temp1_input scale=0.001
+//	command prefix=redis interval=10s timeout=5s exec="redis-queue-length.sh"
+func parseSourceConfig(r io.Reader) ([]*sourceEntry, error) {
+	var entries []*sourceEntry
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		l := strings.TrimSpace(s.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		fields, err := splitConfigFields(l)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		e := &sourceEntry{Kind: fields[0], Params: map[string]string{}, Line: line}
+		for _, f := range fields[1:] {
+			k, v, ok := strings.Cut(f, "=")
+			if !ok || k == "" {
+				return nil, fmt.Errorf("line %d: invalid parameter %q: expected key=value", line, f)
+			}
+			if _, dup := e.Params[k]; dup {
+				return nil, fmt.Errorf("line %d: duplicated parameter %q", line, k)
+			}
+			e.Params[k] = v
+		}
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scanner error: %w", err)
+	}
+	return entries, nil
+}
+
+// splitConfigFields splits a line by spaces. A part of a field can be
+// double quoted to contain spaces, in which \" and \\ are escaped. Other
+// backslashes are kept as is.
+func splitConfigFields(l string) ([]string, error) {
+	var fields []string
+	var b strings.Builder
+	inField, quoted, escaped := false, false, false
+	for _, r := range l {
+		switch {
+		case escaped:
+			if r != '"' && r != '\\' {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inField {
+				fields = append(fields, b.String())
+				b.Reset()
+				inField = false
+			}
+		default:
+			b.WriteRune(r)
+			inField = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, b.String())
+	}
+	return fields, nil
+}
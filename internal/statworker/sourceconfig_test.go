@@ -0,0 +1,90 @@
+package statworker
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitConfigFields(t *testing.T) {
+	tests := map[string][]string{
+		"gauge name=a path=/x":              {"gauge", "name=a", "path=/x"},
+		"  gauge\tname=a  ":                 {"gauge", "name=a"},
+		`command exec="redis-cli info" a=b`: {"command", "exec=redis-cli info", "a=b"},
+		`x "a \"b\" \\c"`:                   {"x", `a "b" \c`},
+		`x a=""`:                            {"x", "a="},
+		`x "s/^/a\t/"`:                      {"x", `s/^/a\t/`},
+	}
+	for input, want := range tests {
+		got, err := splitConfigFields(input)
+		if err != nil {
+			t.Errorf("splitConfigFields(%q) error = %v", input, err)
+			continue
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("splitConfigFields(%q) = %q, want %q", input, got, want)
+		}
+	}
+	if _, err := splitConfigFields(`x "a`); err == nil {
+		t.Error("expected error for unterminated quote")
+	}
+}
+
+func TestParseSourceConfig(t *testing.T) {
+	entries, err := parseSourceConfig(strings.NewReader(`# comment
+
+gauge name=nvme_temp path=/sys/class/hwmon/hwmon2/temp1_input scale=0.001
+counter name=ctx path=/x
+`))
+	if err != nil {
+		t.Fatalf("parseSourceConfig() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Kind != "gauge" || e.Line != 3 || e.Params["name"] != "nvme_temp" || e.Params["scale"] != "0.001" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	for _, invalid := range []string{"gauge name", "gauge =a", "gauge name=a name=b"} {
+		if _, err := parseSourceConfig(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestReadSourceConfig(t *testing.T) {
+	dir := t.TempDir()
+	value := filepath.Join(dir, "value")
+	if err := os.WriteFile(value, []byte("42\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "maxcpu.conf")
+	write := func(s string) {
+		if err := os.WriteFile(config, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("gauge name=a path=" + value + "\ncounter name=b path=" + value + "\n")
+	samplers, err := readSourceConfig(config)
+	if err != nil {
+		t.Fatalf("readSourceConfig() error = %v", err)
+	}
+	if len(samplers) != 2 || samplers[0].Group() != "file.a" || samplers[1].Group() != "file.b" {
+		t.Errorf("unexpected samplers: %v", samplers)
+	}
+
+	for _, invalid := range []string{
+		"unknown name=a path=" + value,
+		"gauge name=a path=" + value + "\ngauge name=a path=" + value,
+		"gauge name=a path=" + filepath.Join(dir, "missing"),
+	} {
+		write(invalid)
+		if _, err := readSourceConfig(config); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
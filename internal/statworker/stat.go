@@ -4,38 +4,43 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
+	"time"
 )
 
+// cpuStat holds the raw cumulative counters of a cpu line in /proc/stat,
+// in USER_HZ jiffies.
 type cpuStat struct {
-	User      float64
-	Nice      float64
-	System    float64
-	Idle      float64
-	Iowait    float64
-	IRQ       float64
-	SoftIRQ   float64
-	Steal     float64
-	Guest     float64
-	GuestNice float64
+	User      uint64
+	Nice      uint64
+	System    uint64
+	Idle      uint64
+	Iowait    uint64
+	IRQ       uint64
+	SoftIRQ   uint64
+	Steal     uint64
+	Guest     uint64
+	GuestNice uint64
+	// CPUs holds the cpuN lines keyed by cpu number, only when requested
+	CPUs map[int]*cpuStat
 }
 
 // cpuLineHeader is the prefix for the CPU line in /proc/stat
 var cpuLineHeader = []byte("cpu ")
 
-// https://github.com/prometheus/procfs/blob/c0c2a8be4d30a2e2cb95ea371a6f32a506d3e45e/proc_stat.go#L40
-var userHZ float64 = 100
-
-func parseCPUstat(b []byte) (float64, error) {
-	f, err := strconv.ParseFloat(string(b), 64)
-	if err != nil {
-		return f, err
-	}
-	return f / userHZ, nil
+func parseCPUstat(b []byte) (uint64, error) {
+	return parseUint(b)
 }
 
 func GetStat() (*cpuStat, error) {
+	return getProcStat(false)
+}
+
+// getProcStat reads /proc/stat. With perCPU, the cpuN lines are also read
+// into CPUs.
+func getProcStat(perCPU bool) (*cpuStat, error) {
 	// read /proc/stat
 	f, err := os.Open("/proc/stat")
 	if err != nil {
@@ -44,96 +49,24 @@ func GetStat() (*cpuStat, error) {
 	defer f.Close()
 
 	// Get the CPU statistics from /proc/stat
+	if perCPU {
+		return getPerCPUStat(f)
+	}
 	return getCPUStat(f)
 }
 
 // cpu  168487 7399 36999 7766545 3915 0 13480 0 0 0
 // qw(cpu-user cpu-nice cpu-system cpu-idle cpu-iowait cpu-irq cpu-softirq cpu-steal cpu-guest cpu-guest-nice);
-func getCPUStat(f *os.File) (*cpuStat, error) {
+func getCPUStat(f io.Reader) (*cpuStat, error) {
 	s := bufio.NewScanner(f)
 	for s.Scan() {
 		l := s.Bytes()
 		if bytes.HasPrefix(l, cpuLineHeader) {
-			if len(l) <= len(cpuLineHeader)+1 {
+			sp := bytes.Fields(l[len(cpuLineHeader):])
+			if len(sp) == 0 {
 				continue // Skip this line if it's too short
 			}
-			fix := 0
-			if l[len(cpuLineHeader)+1] == ' ' {
-				fix = 1
-			}
-			cs := &cpuStat{}
-			sp := bytes.Fields(l[len(cpuLineHeader)+fix+1:])
-			if len(sp) > 0 {
-				f, err := parseCPUstat(sp[0])
-				if err != nil {
-					return nil, err
-				}
-				cs.User = f
-			}
-			if len(sp) > 1 {
-				f, err := parseCPUstat(sp[1])
-				if err != nil {
-					return nil, err
-				}
-				cs.Nice = f
-			}
-			if len(sp) > 2 {
-				f, err := parseCPUstat(sp[2])
-				if err != nil {
-					return nil, err
-				}
-				cs.System = f
-			}
-			if len(sp) > 3 {
-				f, err := parseCPUstat(sp[3])
-				if err != nil {
-					return nil, err
-				}
-				cs.Idle = f
-			}
-			if len(sp) > 4 {
-				f, err := parseCPUstat(sp[4])
-				if err != nil {
-					return nil, err
-				}
-				cs.Iowait = f
-			}
-			if len(sp) > 5 {
-				f, err := parseCPUstat(sp[5])
-				if err != nil {
-					return nil, err
-				}
-				cs.IRQ = f
-			}
-			if len(sp) > 6 {
-				f, err := parseCPUstat(sp[6])
-				if err != nil {
-					return nil, err
-				}
-				cs.SoftIRQ = f
-			}
-			if len(sp) > 7 {
-				f, err := parseCPUstat(sp[7])
-				if err != nil {
-					return nil, err
-				}
-				cs.Steal = f
-			}
-			if len(sp) > 8 {
-				f, err := parseCPUstat(sp[8])
-				if err != nil {
-					return nil, err
-				}
-				cs.Guest = f
-			}
-			if len(sp) > 9 {
-				f, err := parseCPUstat(sp[9])
-				if err != nil {
-					return nil, err
-				}
-				cs.GuestNice = f
-			}
-			return cs, nil
+			return parseCPULine(sp)
 		}
 	}
 	if err := s.Err(); err != nil {
@@ -141,3 +74,108 @@ func getCPUStat(f *os.File) (*cpuStat, error) {
 	}
 	return nil, fmt.Errorf("no cpu stats found in /proc/stat")
 }
+
+// getPerCPUStat reads the aggregated cpu line and the following cpuN lines.
+func getPerCPUStat(f io.Reader) (*cpuStat, error) {
+	var cs *cpuStat
+	cpus := map[int]*cpuStat{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := s.Bytes()
+		if !bytes.HasPrefix(l, cpuLineHeader[:3]) {
+			if cs != nil {
+				break // cpu lines are contiguous
+			}
+			continue
+		}
+		sp := bytes.Fields(l)
+		if len(sp) < 2 {
+			continue
+		}
+		st, err := parseCPULine(sp[1:])
+		if err != nil {
+			return nil, err
+		}
+		if len(sp[0]) == 3 {
+			cs = st
+			continue
+		}
+		id, err := strconv.Atoi(string(sp[0][3:]))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected cpu line %q: %w", sp[0], err)
+		}
+		cpus[id] = st
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scanner error: %w", err)
+	}
+	if cs == nil {
+		return nil, fmt.Errorf("no cpu stats found in /proc/stat")
+	}
+	cs.CPUs = cpus
+	return cs, nil
+}
+
+// parseCPULine parses the counters following the label of a cpu line.
+// Older kernels have fewer columns, the missing ones are left as zero.
+func parseCPULine(sp [][]byte) (*cpuStat, error) {
+	cs := &cpuStat{}
+	fields := []*uint64{
+		&cs.User,
+		&cs.Nice,
+		&cs.System,
+		&cs.Idle,
+		&cs.Iowait,
+		&cs.IRQ,
+		&cs.SoftIRQ,
+		&cs.Steal,
+		&cs.Guest,
+		&cs.GuestNice,
+	}
+	for i, field := range fields {
+		if len(sp) <= i {
+			break
+		}
+		f, err := parseCPUstat(sp[i])
+		if err != nil {
+			return nil, err
+		}
+		*field = f
+	}
+	return cs, nil
+}
+
+// procStatSampler is the Sampler of the cpu usage in /proc/stat. The whole
+// sample is kept in last for the Worker to store, since the stats of the
+// cpus and the peak second lookups of the other sources are computed from
+// it.
+type procStatSampler struct {
+	perCPU          bool
+	guestCorrection bool
+	// last is the latest sample, the baseline after the first call
+	last *cpuUsage
+}
+
+func (s *procStatSampler) Group() string {
+	return usageGroup
+}
+
+// Sample reads /proc/stat and returns the cpu usage since the previous
+// call. It returns nil at the first call.
+func (s *procStatSampler) Sample(_ time.Time) (map[string]float64, error) {
+	cpu, err := getProcStat(s.perCPU)
+	if err != nil {
+		return nil, err
+	}
+	return s.add(cpu), nil
+}
+
+// add makes a sample from the counters and the previous sample.
+func (s *procStatSampler) add(cpu *cpuStat) map[string]float64 {
+	prev := s.last
+	s.last = newCPUUsage(cpu, prev, s.guestCorrection)
+	if prev == nil {
+		return nil
+	}
+	return map[string]float64{"": s.last.Usage}
+}
@@ -9,13 +9,15 @@ import (
 func TestParseCPUstat(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected float64
+		expected uint64
 		wantErr  bool
 	}{
-		{"100", 1.0, false},
-		{"0", 0.0, false},
-		{"200", 2.0, false},
+		{"100", 100, false},
+		{"0", 0, false},
+		{"200", 200, false},
+		{"18446744073709551615", 18446744073709551615, false},
 		{"abc", 0, true},
+		{"-1", 0, true},
 	}
 
 	for _, tt := range tests {
@@ -93,7 +95,7 @@ func TestGetCPUStat_HandlesExtraSpaces(t *testing.T) {
 	if err != nil {
 		t.Fatalf("getCPUStat() error = %v", err)
 	}
-	if stat.User != 1.0 || stat.Nice != 2.0 || stat.System != 3.0 || stat.Idle != 4.0 {
+	if stat.User != 100 || stat.Nice != 200 || stat.System != 300 || stat.Idle != 400 {
 		t.Errorf("Unexpected parsed values: %+v", stat)
 	}
 }
@@ -108,7 +110,27 @@ func TestGetCPUStat_HandlesMissingFields(t *testing.T) {
 	if err != nil {
 		t.Fatalf("getCPUStat() error = %v", err)
 	}
-	if stat.User != 1.0 || stat.Nice != 2.0 || stat.System != 3.0 || stat.Idle != 4.0 {
+	if stat.User != 100 || stat.Nice != 200 || stat.System != 300 || stat.Idle != 400 {
 		t.Errorf("Unexpected parsed values: %+v", stat)
 	}
 }
+
+func TestGetPerCPUStat(t *testing.T) {
+	procStat := "cpu  300 0 0 300 0 0 0 0 0 0\ncpu0 100 0 0 200 0 0 0 0 0 0\ncpu2 200 0 0 100 0 0 0 0 0 0\nintr 12345\ncpu9 1 1 1 1\n"
+	f := tmpFileWithContent(t, procStat)
+	defer os.Remove(f.Name())
+
+	stat, err := getPerCPUStat(f)
+	if err != nil {
+		t.Fatalf("getPerCPUStat() error = %v", err)
+	}
+	if stat.User != 300 || stat.Idle != 300 {
+		t.Errorf("Unexpected aggregated values: %+v", stat)
+	}
+	if len(stat.CPUs) != 2 {
+		t.Fatalf("expected 2 cpus, got %d", len(stat.CPUs))
+	}
+	if stat.CPUs[0].User != 100 || stat.CPUs[2].User != 200 || stat.CPUs[2].Idle != 100 {
+		t.Errorf("Unexpected per-cpu values: %+v %+v", stat.CPUs[0], stat.CPUs[2])
+	}
+}
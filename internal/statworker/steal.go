@@ -0,0 +1,37 @@
+package statworker
+
+import (
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// stealUsage returns the steal time of a sample in percent.
+func stealUsage(u *cpuUsage) float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return float64(u.GapSteal) / float64(u.Total) * 100.0
+}
+
+// stealMetrics reports the max steal in the samples, the ratio of steal to
+// busy time at the second the cpu usage peaked, and the number of seconds
+// with steal at or above threshold.
+func stealMetrics(samples []*cpuUsage, threshold float64, epoch int64) []*maxcpu.Metric {
+	var peak float64
+	var over int
+	for _, u := range samples {
+		steal := stealUsage(u)
+		peak = max(peak, steal)
+		if steal >= threshold {
+			over++
+		}
+	}
+	var ratio float64
+	if u := peakSample(samples); u != nil && u.Busy > 0 {
+		ratio = float64(u.GapSteal) / float64(u.Busy) * 100.0
+	}
+	return []*maxcpu.Metric{
+		{Group: "steal", Key: "max_usage", Metric: peak, Epoch: epoch},
+		{Group: "steal", Key: "busy_ratio_at_peak", Metric: ratio, Epoch: epoch},
+		{Group: "steal_seconds", Key: "over_threshold", Metric: float64(over), Epoch: epoch},
+	}
+}
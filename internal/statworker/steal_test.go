@@ -0,0 +1,31 @@
+package statworker
+
+import (
+	"testing"
+)
+
+func TestStealMetrics(t *testing.T) {
+	samples := []*cpuUsage{
+		{GapSteal: 5, Busy: 50, Total: 100, Usage: 50},
+		{GapSteal: 30, Busy: 60, Total: 100, Usage: 60},
+		{GapSteal: 20, Busy: 80, Total: 100, Usage: 80},
+		{},
+	}
+	got := map[string]float64{}
+	for _, m := range stealMetrics(samples, 10, 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	want := map[string]float64{
+		"steal.max_usage":              30,
+		"steal.busy_ratio_at_peak":     25,
+		"steal_seconds.over_threshold": 2,
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d metrics, got %v", len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package statworker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// thermalSysfs is the sysfs directory of thermal zones
+var thermalSysfs = "/sys/class/thermal"
+
+type thermalZone struct {
+	Label string
+	Dir   string
+}
+
+// findThermalZones finds thermal_zoneN labeled by their type. Zones sharing
+// a type are suffixed with the zone number.
+func findThermalZones(dir string) ([]*thermalZone, error) {
+	dirs, err := filepath.Glob(filepath.Join(dir, "thermal_zone[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	var zones []*thermalZone
+	types := map[string]int{}
+	for _, d := range dirs {
+		b, err := os.ReadFile(filepath.Join(d, "type"))
+		if err != nil {
+			return nil, err
+		}
+		typ := metricKey(strings.TrimSpace(string(b)))
+		types[typ]++
+		zones = append(zones, &thermalZone{Label: typ, Dir: d})
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("no thermal zones found in %s", dir)
+	}
+	for _, z := range zones {
+		if types[z.Label] > 1 {
+			z.Label += "_" + strings.TrimPrefix(filepath.Base(z.Dir), "thermal_zone")
+		}
+	}
+	return zones, nil
+}
+
+type thermalSampler struct {
+	zones []*thermalZone
+}
+
+func newThermalSampler(dir string) (*thermalSampler, error) {
+	zones, err := findThermalZones(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &thermalSampler{zones: zones}, nil
+}
+
+func (s *thermalSampler) Group() string {
+	return "thermal_zone_celsius"
+}
+
+// Sample returns the temperature in celsius keyed by zone label. A zone
+// failing to read, such as a sensor of a suspended device, is skipped.
+func (s *thermalSampler) Sample(_ time.Time) (map[string]float64, error) {
+	temps := map[string]float64{}
+	for _, z := range s.zones {
+		b, err := os.ReadFile(filepath.Join(z.Dir, "temp"))
+		if err != nil {
+			continue
+		}
+		t, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			continue
+		}
+		temps[z.Label] = float64(t) / 1000
+	}
+	return temps, nil
+}
@@ -0,0 +1,44 @@
+package statworker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThermalSampler(t *testing.T) {
+	root := writeSysfs(t, map[string]string{
+		"thermal_zone0/type":   "acpitz\n",
+		"thermal_zone0/temp":   "27800\n",
+		"thermal_zone1/type":   "x86_pkg_temp\n",
+		"thermal_zone1/temp":   "65000\n",
+		"thermal_zone2/type":   "acpitz\n",
+		"thermal_zone2/temp":   "-5000\n",
+		"thermal_zone3/type":   "iwlwifi_1\n",
+		"cooling_device0/type": "Processor\n",
+	})
+	s, err := newThermalSampler(root)
+	if err != nil {
+		t.Fatalf("newThermalSampler() error = %v", err)
+	}
+	v, err := s.Sample(time.Now())
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	temps := v
+	want := map[string]float64{
+		"acpitz_0":     27.8,
+		"x86_pkg_temp": 65,
+		"acpitz_2":     -5,
+	}
+	if len(temps) != len(want) {
+		t.Errorf("expected %d zones, got %v", len(want), temps)
+	}
+	for k, v := range want {
+		if temps[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, temps[k])
+		}
+	}
+	if _, err := newThermalSampler(t.TempDir()); err == nil {
+		t.Error("expected error without thermal zones")
+	}
+}
@@ -0,0 +1,146 @@
+package statworker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// cpuSysfs is the sysfs directory of cpus
+var cpuSysfs = "/sys/devices/system/cpu"
+
+// coreSaturatedThreshold is the usage a physical core is counted as saturated at
+const coreSaturatedThreshold = 90.0
+
+type physicalCore struct {
+	Socket int
+	Core   int
+	CPUs   []int
+}
+
+func (c *physicalCore) label() string {
+	return fmt.Sprintf("socket%d_core%d", c.Socket, c.Core)
+}
+
+type topology struct {
+	Cores []*physicalCore
+}
+
+// readTopology groups the online cpus into physical cores by
+// thread_siblings_list, labeled with physical_package_id and core_id.
+func readTopology(dir string) (*topology, error) {
+	online, err := readCPUList(filepath.Join(dir, "online"))
+	if err != nil {
+		return nil, err
+	}
+	t := &topology{}
+	seen := map[string]bool{}
+	for _, cpu := range online {
+		base := filepath.Join(dir, fmt.Sprintf("cpu%d", cpu), "topology")
+		b, err := os.ReadFile(filepath.Join(base, "thread_siblings_list"))
+		if err != nil {
+			return nil, err
+		}
+		siblings := strings.TrimSpace(string(b))
+		if seen[siblings] {
+			continue
+		}
+		seen[siblings] = true
+		cpus, err := parseCPUList(siblings)
+		if err != nil {
+			return nil, err
+		}
+		socket, err := readInt(filepath.Join(base, "physical_package_id"))
+		if err != nil {
+			return nil, err
+		}
+		core, err := readInt(filepath.Join(base, "core_id"))
+		if err != nil {
+			return nil, err
+		}
+		t.Cores = append(t.Cores, &physicalCore{Socket: socket, Core: core, CPUs: cpus})
+	}
+	if len(t.Cores) == 0 {
+		return nil, fmt.Errorf("no online cpus found in %s", dir)
+	}
+	sort.Slice(t.Cores, func(i, j int) bool {
+		if t.Cores[i].Socket != t.Cores[j].Socket {
+			return t.Cores[i].Socket < t.Cores[j].Socket
+		}
+		return t.Cores[i].Core < t.Cores[j].Core
+	})
+	return t, nil
+}
+
+// coreUsage returns the usage of a physical core in a sample, which is the
+// busiest of its threads since the core is occupied while any sibling runs.
+func coreUsage(c *physicalCore, u *cpuUsage) float64 {
+	usage := 0.0
+	for _, cpu := range c.CPUs {
+		if cu, ok := u.CPUs[cpu]; ok && cu.Total > 0 && cu.Usage > usage {
+			usage = cu.Usage
+		}
+	}
+	return usage
+}
+
+// metrics reports the peak usage of each physical core and socket, and the
+// peak number of saturated physical cores in the samples. The usage of a
+// socket is the average of its physical cores.
+func (t *topology) metrics(samples []*cpuUsage, epoch int64) []*maxcpu.Metric {
+	coreMax := make([]float64, len(t.Cores))
+	socketMax := map[int]float64{}
+	saturatedMax := 0
+	for _, u := range samples {
+		socketTotal := map[int]float64{}
+		socketCores := map[int]int{}
+		saturated := 0
+		for i, c := range t.Cores {
+			usage := coreUsage(c, u)
+			coreMax[i] = max(coreMax[i], usage)
+			if usage >= coreSaturatedThreshold {
+				saturated++
+			}
+			socketTotal[c.Socket] += usage
+			socketCores[c.Socket]++
+		}
+		for socket, total := range socketTotal {
+			socketMax[socket] = max(socketMax[socket], total/float64(socketCores[socket]))
+		}
+		saturatedMax = max(saturatedMax, saturated)
+	}
+
+	res := make([]*maxcpu.Metric, 0, len(t.Cores)+len(socketMax)+1)
+	for i, c := range t.Cores {
+		res = append(res, &maxcpu.Metric{
+			Group:  "physical_core_max_usage",
+			Key:    c.label(),
+			Metric: coreMax[i],
+			Epoch:  epoch,
+		})
+	}
+	sockets := make([]int, 0, len(socketMax))
+	for socket := range socketMax {
+		sockets = append(sockets, socket)
+	}
+	sort.Ints(sockets)
+	for _, socket := range sockets {
+		res = append(res, &maxcpu.Metric{
+			Group:  "socket_max_usage",
+			Key:    fmt.Sprintf("socket%d", socket),
+			Metric: socketMax[socket],
+			Epoch:  epoch,
+		})
+	}
+	res = append(res, &maxcpu.Metric{
+		Group:  "physical_core_saturated",
+		Key:    "max",
+		Metric: float64(saturatedMax),
+		Epoch:  epoch,
+	})
+	return res
+}
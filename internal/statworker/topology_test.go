@@ -0,0 +1,105 @@
+package statworker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSysfs creates a sysfs like fixture tree under a temp dir
+func writeSysfs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("failed to create fixture dir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+	return root
+}
+
+// 2 sockets, 2 cores per socket, 2 threads per core
+func smtSysfs(t *testing.T) string {
+	t.Helper()
+	files := map[string]string{"online": "0-7\n"}
+	siblings := []string{"0,4", "1,5", "2,6", "3,7", "0,4", "1,5", "2,6", "3,7"}
+	for cpu := 0; cpu < 8; cpu++ {
+		dir := filepath.Join(fmt.Sprintf("cpu%d", cpu), "topology")
+		files[filepath.Join(dir, "thread_siblings_list")] = siblings[cpu] + "\n"
+		files[filepath.Join(dir, "physical_package_id")] = fmt.Sprintf("%d\n", cpu%4/2)
+		files[filepath.Join(dir, "core_id")] = fmt.Sprintf("%d\n", cpu%2)
+	}
+	return writeSysfs(t, files)
+}
+
+func TestReadTopology(t *testing.T) {
+	topo, err := readTopology(smtSysfs(t))
+	if err != nil {
+		t.Fatalf("readTopology() error = %v", err)
+	}
+	if len(topo.Cores) != 4 {
+		t.Fatalf("expected 4 physical cores, got %d", len(topo.Cores))
+	}
+	want := []string{"socket0_core0", "socket0_core1", "socket1_core0", "socket1_core1"}
+	for i, c := range topo.Cores {
+		if c.label() != want[i] {
+			t.Errorf("core %d: expected %s, got %s", i, want[i], c.label())
+		}
+		if len(c.CPUs) != 2 {
+			t.Errorf("core %s: expected 2 threads, got %v", c.label(), c.CPUs)
+		}
+	}
+}
+
+func TestReadTopology_Missing(t *testing.T) {
+	root := writeSysfs(t, map[string]string{"online": "0\n"})
+	if _, err := readTopology(root); err == nil {
+		t.Error("expected error for missing topology files")
+	}
+}
+
+func perCPUSample(usages map[int]float64) *cpuUsage {
+	u := &cpuUsage{CPUs: map[int]*cpuUsage{}}
+	for cpu, usage := range usages {
+		u.CPUs[cpu] = &cpuUsage{Total: 100, Usage: usage}
+	}
+	return u
+}
+
+func TestTopologyMetrics(t *testing.T) {
+	topo, err := readTopology(smtSysfs(t))
+	if err != nil {
+		t.Fatalf("readTopology() error = %v", err)
+	}
+	samples := []*cpuUsage{
+		// one thread of every core busy: 50% by threads
+		perCPUSample(map[int]float64{0: 100, 1: 100, 2: 100, 3: 100, 4: 0, 5: 0, 6: 0, 7: 0}),
+		perCPUSample(map[int]float64{0: 10, 1: 20, 2: 0, 3: 0, 4: 30, 5: 0, 6: 0, 7: 0}),
+	}
+	got := map[string]float64{}
+	for _, m := range topo.metrics(samples, 1) {
+		got[m.Group+"."+m.Key] = m.Metric
+	}
+	want := map[string]float64{
+		"physical_core_max_usage.socket0_core0": 100,
+		"physical_core_max_usage.socket0_core1": 100,
+		"physical_core_max_usage.socket1_core0": 100,
+		"physical_core_max_usage.socket1_core1": 100,
+		"socket_max_usage.socket0":              100,
+		"socket_max_usage.socket1":              100,
+		"physical_core_saturated.max":           4,
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d metrics, got %v", len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+}
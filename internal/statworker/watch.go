@@ -0,0 +1,95 @@
+package statworker
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// watchBuffer is the number of samples kept for a slow watcher before
+// dropping.
+const watchBuffer = 10
+
+// watch registers a watcher receiving the samples as they are taken. The
+// samples are dropped while the watcher is behind by watchBuffer. The
+// returned function unregisters it.
+func (w *Worker) watch() (<-chan *cpuUsage, func()) {
+	ch := make(chan *cpuUsage, watchBuffer)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.watchers[ch] = struct{}{}
+	return ch, func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		delete(w.watchers, ch)
+	}
+}
+
+// notify sends the latest sample to the watchers. It is called after the
+// sample is stored with the values of the samplers, for the stats to
+// include them.
+func (w *Worker) notify() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.current == 0 {
+		return
+	}
+	u := w.usages[w.current]
+	for ch := range w.watchers {
+		select {
+		case ch <- u:
+		default:
+		}
+	}
+}
+
+// WatchUsage streams each sample as it is taken, or the stats of every
+// Interval seconds. Watching keeps the daemon from stopping as idle.
+func (w *Worker) WatchUsage(ctx context.Context, req *connect.Request[maxcpu.WatchRequest], stream *connect.ServerStream[maxcpu.WatchResponse]) error {
+	interval := req.Msg.Interval
+	if interval < 0 || interval > historySize-1 {
+		return fmt.Errorf("interval must be between 0 and %d seconds", historySize-1)
+	}
+	ch, stop := w.watch()
+	defer stop()
+
+	// cursor is the sequence number the stats are reported up to
+	var cursor uint64
+	var started bool
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case u := <-ch:
+			atomic.StoreInt64(&w.idleTime, 0)
+			res := &maxcpu.WatchResponse{}
+			if interval <= 1 {
+				res.Sample = toSample(u)
+			} else {
+				if !started {
+					// summarize from the first sample received
+					cursor = u.Seq - 1
+					started = true
+				}
+				// by sequence number, as samples are dropped for a slow watcher
+				if u.Seq-cursor < uint64(interval) {
+					continue
+				}
+				w.lock.Lock()
+				metrics, err := w.statsSince(cursor)
+				w.lock.Unlock()
+				cursor = u.Seq
+				if err != nil {
+					continue
+				}
+				res.Metrics = metrics
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+	}
+}
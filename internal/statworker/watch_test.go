@@ -0,0 +1,129 @@
+package statworker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu/maxcpuconnect"
+)
+
+// startWatch starts streaming from the worker and waits for the watcher to
+// be registered.
+func startWatch(t *testing.T, w *Worker, interval int32) *connect.ServerStreamForClient[maxcpu.WatchResponse] {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(maxcpuconnect.NewMaxCPUHandler(w))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	client := maxcpuconnect.NewMaxCPUClient(srv.Client(), srv.URL)
+	stream, err := client.WatchUsage(ctx, connect.NewRequest(&maxcpu.WatchRequest{Interval: interval}))
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stream.Close() })
+	// cancel before closing, which waits for the end of the stream
+	t.Cleanup(cancel)
+	for range 100 {
+		w.lock.Lock()
+		n := len(w.watchers)
+		w.lock.Unlock()
+		if n > 0 {
+			return stream
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("watcher is not registered")
+	return nil
+}
+
+func TestWatchUsage(t *testing.T) {
+	w := newWorker(Config{})
+	w.calculatingGap(&cpuStat{})
+	stream := startWatch(t, w, 0)
+
+	w.calculatingGap(&cpuStat{User: 100, Idle: 100})
+	w.notify()
+	if !stream.Receive() {
+		t.Fatalf("expected a sample: %v", stream.Err())
+	}
+	s := stream.Msg().Sample
+	if s == nil || s.Seq != 1 || s.Usage != 50 {
+		t.Errorf("unexpected sample: %v", stream.Msg())
+	}
+}
+
+func TestWatchUsage_Interval(t *testing.T) {
+	w := newWorker(Config{})
+	w.calculatingGap(&cpuStat{})
+	w.calculatingGap(&cpuStat{User: 10, Idle: 90})
+	stream := startWatch(t, w, 2)
+
+	// 20% and 40% busy
+	for _, cpu := range []*cpuStat{{User: 30, Idle: 170}, {User: 70, Idle: 230}} {
+		w.calculatingGap(cpu)
+		w.notify()
+	}
+	if !stream.Receive() {
+		t.Fatalf("expected stats: %v", stream.Err())
+	}
+	got := map[string]float64{}
+	for _, m := range stream.Msg().Metrics {
+		got[m.Key] = m.Metric
+	}
+	// the samples before watching are not included
+	if got["min"] != 20 || got["max"] != 40 {
+		t.Errorf("unexpected stats: %v", got)
+	}
+}
+
+func TestWatchUsage_InvalidInterval(t *testing.T) {
+	w := newWorker(Config{})
+	srv := httptest.NewServer(func() http.Handler {
+		mux := http.NewServeMux()
+		mux.Handle(maxcpuconnect.NewMaxCPUHandler(w))
+		return mux
+	}())
+	defer srv.Close()
+	client := maxcpuconnect.NewMaxCPUClient(srv.Client(), srv.URL)
+	stream, err := client.WatchUsage(context.Background(), connect.NewRequest(&maxcpu.WatchRequest{Interval: -1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if stream.Receive() || stream.Err() == nil {
+		t.Error("expected error for invalid interval")
+	}
+}
+
+func TestWatchUsage_Dropped(t *testing.T) {
+	w := newWorker(Config{})
+	w.calculatingGap(&cpuStat{})
+	stream := startWatch(t, w, 3)
+
+	// 10%, 20% and 30% busy, the second sample dropped for the watcher
+	for i, cpu := range []*cpuStat{{User: 10, Idle: 90}, {User: 30, Idle: 170}, {User: 60, Idle: 240}} {
+		w.calculatingGap(cpu)
+		if i != 1 {
+			w.notify()
+		}
+	}
+	if !stream.Receive() {
+		t.Fatalf("expected stats: %v", stream.Err())
+	}
+	got := map[string]float64{}
+	for _, m := range stream.Msg().Metrics {
+		got[m.Key] = m.Metric
+	}
+	// from the first sample, including the dropped one
+	if got["min"] != 10 || got["max"] != 30 {
+		t.Errorf("unexpected stats: %v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package statworker
 
 import (
+	"fmt"
 	"log"
 	"sync"
 	"sync/atomic"
@@ -12,30 +13,57 @@ type Worker struct {
 	current  int64
 	lock     sync.Mutex
 	idleTime int64
+	cfg      Config
+	topology *topology
+	nodes    []*numaNode
+	groups   []*cpuGroup
+	// procStat is the first of the samplers, whose whole samples are kept
+	// in usages for the stats of the cpus and the peak second lookups
+	procStat *procStatSampler
+	// samplers are sampled every second, /proc/stat first
+	samplers []*samplerSeries
+	// seq is the sequence number of the latest sample
+	seq uint64
+	// consumers holds the sequence number each consumer has read up to
+	consumers map[string]uint64
+	// startedAt identifies the worker the sequence numbers belong to
+	startedAt int64
+	// watchers receive the samples as they are taken
+	watchers map[chan *cpuUsage]struct{}
 }
 
+// cpuUsage is a sample of /proc/stat. Counters and gaps are kept in jiffies
+// so that the percentage is only computed once from exact integers.
 type cpuUsage struct {
-	User         float64
-	Nice         float64
-	System       float64
-	Idle         float64
-	Iowait       float64
-	IRQ          float64
-	SoftIRQ      float64
-	Steal        float64
-	Guest        float64
-	GuestNice    float64
-	GapUser      float64
-	GapNice      float64
-	GapSystem    float64
-	GapIdle      float64
-	GapIowait    float64
-	GapIRQ       float64
-	GapSoftIRQ   float64
-	GapSteal     float64
-	GapGuest     float64
-	GapGuestNice float64
+	// Seq is the sequence number of the sample, 0 for the baseline
+	Seq uint64
+	// Time is when the sample was taken
+	Time         time.Time
+	User         uint64
+	Nice         uint64
+	System       uint64
+	Idle         uint64
+	Iowait       uint64
+	IRQ          uint64
+	SoftIRQ      uint64
+	Steal        uint64
+	Guest        uint64
+	GuestNice    uint64
+	GapUser      uint64
+	GapNice      uint64
+	GapSystem    uint64
+	GapIdle      uint64
+	GapIowait    uint64
+	GapIRQ       uint64
+	GapSoftIRQ   uint64
+	GapSteal     uint64
+	GapGuest     uint64
+	GapGuestNice uint64
+	Busy         uint64
+	Total        uint64
 	Usage        float64
+	// CPUs holds the samples of each cpu when per-cpu stats are enabled
+	CPUs map[int]*cpuUsage
 }
 
 // historySize defines the maximum number of CPU usage records to retain.
@@ -44,75 +72,234 @@ type cpuUsage struct {
 const historySize = 361
 
 func New() *Worker {
+	return newWorker(Config{})
+}
+
+// NewWithConfig returns a Worker with the options. It reads the cpu topology,
+// NUMA nodes and cpu groups when their stats are enabled.
+func NewWithConfig(cfg Config) (*Worker, error) {
+	w := newWorker(cfg)
+	if cfg.PhysicalCores {
+		t, err := readTopology(cpuSysfs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cpu topology: %w", err)
+		}
+		w.topology = t
+		w.procStat.perCPU = true
+	}
+	if cfg.CoreSkew {
+		w.procStat.perCPU = true
+	}
+	if cfg.NUMA {
+		nodes, err := readNUMANodes(nodeSysfs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NUMA nodes: %w", err)
+		}
+		w.nodes = nodes
+		w.procStat.perCPU = true
+	}
+	for _, s := range cfg.CPUGroups {
+		g, err := parseCPUGroup(s)
+		if err != nil {
+			return nil, err
+		}
+		w.groups = append(w.groups, g)
+		w.procStat.perCPU = true
+	}
+	if cfg.SoftIRQs || cfg.SoftIRQsPerCPU {
+		w.addSampler(newSoftirqSampler(cfg.SoftIRQsPerCPU))
+	}
+	if cfg.Interrupts {
+		w.addSampler(newIRQSampler())
+	}
+	if cfg.Schedstat || cfg.SchedstatPerCPU {
+		s, err := newSchedstatSampler(cfg.SchedstatPerCPU)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schedstat: %w", err)
+		}
+		w.addSampler(s)
+	}
+	if cfg.CPUIdle {
+		s, err := newCPUIdleSampler(cpuSysfs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cpuidle states: %w", err)
+		}
+		w.addSampler(s)
+	}
+	if cfg.CPUFreq || cfg.FreqWeightedUsage {
+		s, err := newCPUFreqSampler(cpuSysfs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cpufreq: %w", err)
+		}
+		if cfg.FreqWeightedUsage && !s.hasMaxFreq {
+			return nil, fmt.Errorf("failed to read cpuinfo_max_freq for frequency weighted usage")
+		}
+		s.weighted = cfg.FreqWeightedUsage
+		w.addSampler(s)
+	}
+	if cfg.RAPL {
+		s, err := newRAPLSampler(powercapSysfs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RAPL: %w", err)
+		}
+		w.addSampler(s)
+	}
+	if cfg.Thermal {
+		s, err := newThermalSampler(thermalSysfs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read thermal zones: %w", err)
+		}
+		w.addSampler(s)
+	}
+	if cfg.Diskstats {
+		w.addSampler(newDiskstatsSampler())
+	}
+	if cfg.NetDev {
+		w.addSampler(newNetDevSampler())
+	}
+	if cfg.SourcesConfig != "" {
+		samplers, err := readSourceConfig(cfg.SourcesConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sources config: %w", err)
+		}
+		for _, s := range samplers {
+			w.addSampler(s)
+		}
+	}
+	return w, nil
+}
+
+func newWorker(cfg Config) *Worker {
 	usages := make([]*cpuUsage, historySize)
-	return &Worker{
-		usages:   usages,
-		current:  0,
-		idleTime: 0,
+	w := &Worker{
+		usages:    usages,
+		current:   0,
+		idleTime:  0,
+		cfg:       cfg,
+		procStat:  &procStatSampler{guestCorrection: cfg.GuestCorrection},
+		consumers: map[string]uint64{},
+		startedAt: time.Now().UnixNano(),
+		watchers:  map[chan *cpuUsage]struct{}{},
 	}
+	w.addSampler(w.procStat)
+	return w
 }
 
+// calculatingGap stores a sample of the /proc/stat counters, as tick does
+// with the counters read. The first call only stores the baseline.
 func (w *Worker) calculatingGap(cpu *cpuStat) {
+	w.store([]map[string]float64{w.procStat.add(cpu)})
+}
+
+// store stores the latest sample of /proc/stat to the ring buffer, and the
+// values of the samplers, in the order of the samplers, to the rings of
+// their series. The first sample is only stored as the baseline.
+func (w *Worker) store(values []map[string]float64) {
 	w.lock.Lock()
 	defer w.lock.Unlock()
+	u := w.procStat.last
 	if w.usages[0] == nil {
 		// first time
-		w.usages[0] = &cpuUsage{
-			User:      cpu.User,
-			Nice:      cpu.Nice,
-			System:    cpu.System,
-			Idle:      cpu.Idle,
-			Iowait:    cpu.Iowait,
-			IRQ:       cpu.IRQ,
-			SoftIRQ:   cpu.SoftIRQ,
-			Steal:     cpu.Steal,
-			Guest:     cpu.Guest,
-			GuestNice: cpu.GuestNice,
-		}
+		w.usages[0] = u
 		return
 	}
 	next := w.current + 1
 	if next >= historySize {
 		next = 1
 	}
-	w.usages[next] = &cpuUsage{
-		User:         cpu.User,
-		Nice:         cpu.Nice,
-		System:       cpu.System,
-		Idle:         cpu.Idle,
-		Iowait:       cpu.Iowait,
-		IRQ:          cpu.IRQ,
-		SoftIRQ:      cpu.SoftIRQ,
-		Steal:        cpu.Steal,
-		Guest:        cpu.Guest,
-		GuestNice:    cpu.GuestNice,
-		GapUser:      cpu.User - w.usages[w.current].User,
-		GapNice:      cpu.Nice - w.usages[w.current].Nice,
-		GapSystem:    cpu.System - w.usages[w.current].System,
-		GapIdle:      cpu.Idle - w.usages[w.current].Idle,
-		GapIowait:    cpu.Iowait - w.usages[w.current].Iowait,
-		GapIRQ:       cpu.IRQ - w.usages[w.current].IRQ,
-		GapSoftIRQ:   cpu.SoftIRQ - w.usages[w.current].SoftIRQ,
-		GapSteal:     cpu.Steal - w.usages[w.current].Steal,
-		GapGuest:     cpu.Guest - w.usages[w.current].Guest,
-		GapGuestNice: cpu.GuestNice - w.usages[w.current].GuestNice,
-	}
-	w.usages[next].Usage = ((w.usages[next].GapUser +
-		w.usages[next].GapSystem +
-		w.usages[next].GapIowait +
-		w.usages[next].GapSoftIRQ +
-		w.usages[next].GapSteal) /
-		(w.usages[next].GapUser +
-			w.usages[next].GapNice +
-			w.usages[next].GapSystem +
-			w.usages[next].GapIdle +
-			w.usages[next].GapIowait +
-			w.usages[next].GapIRQ +
-			w.usages[next].GapSoftIRQ +
-			w.usages[next].GapSteal +
-			w.usages[next].GapGuest +
-			w.usages[next].GapGuestNice)) * 100.0
+	w.seq++
+	u.Seq = w.seq
+	u.Time = time.Now()
+	w.usages[next] = u
 	w.current = next
+	for i, v := range values {
+		w.samplers[i].push(w.seq, v)
+	}
+}
+
+// newCPUUsage makes a sample from the counters. The gaps and usage are
+// calculated only when the previous sample is given.
+func newCPUUsage(cpu *cpuStat, prev *cpuUsage, guestCorrection bool) *cpuUsage {
+	u := &cpuUsage{
+		User:      cpu.User,
+		Nice:      cpu.Nice,
+		System:    cpu.System,
+		Idle:      cpu.Idle,
+		Iowait:    cpu.Iowait,
+		IRQ:       cpu.IRQ,
+		SoftIRQ:   cpu.SoftIRQ,
+		Steal:     cpu.Steal,
+		Guest:     cpu.Guest,
+		GuestNice: cpu.GuestNice,
+	}
+	if prev != nil {
+		u.GapUser = gap(cpu.User, prev.User)
+		u.GapNice = gap(cpu.Nice, prev.Nice)
+		u.GapSystem = gap(cpu.System, prev.System)
+		u.GapIdle = gap(cpu.Idle, prev.Idle)
+		u.GapIowait = gap(cpu.Iowait, prev.Iowait)
+		u.GapIRQ = gap(cpu.IRQ, prev.IRQ)
+		u.GapSoftIRQ = gap(cpu.SoftIRQ, prev.SoftIRQ)
+		u.GapSteal = gap(cpu.Steal, prev.Steal)
+		u.GapGuest = gap(cpu.Guest, prev.Guest)
+		u.GapGuestNice = gap(cpu.GuestNice, prev.GuestNice)
+		u.calculateUsage(guestCorrection)
+	}
+	if cpu.CPUs != nil {
+		u.CPUs = make(map[int]*cpuUsage, len(cpu.CPUs))
+		for id, c := range cpu.CPUs {
+			var p *cpuUsage
+			if prev != nil {
+				// nil when the cpu has just come online
+				p = prev.CPUs[id]
+			}
+			u.CPUs[id] = newCPUUsage(c, p, guestCorrection)
+		}
+	}
+	return u
+}
+
+// calculateUsage computes Usage from the gaps. The kernel accounts guest and
+// guest_nice inside user and nice as well, so with guestCorrection they are
+// subtracted from GapUser and GapNice to avoid counting them twice in the
+// total. Guest time is still busy time of the host.
+func (u *cpuUsage) calculateUsage(guestCorrection bool) {
+	if guestCorrection {
+		u.GapUser = gap(u.GapUser, u.GapGuest)
+		u.GapNice = gap(u.GapNice, u.GapGuestNice)
+	}
+	busy := u.GapUser +
+		u.GapSystem +
+		u.GapIowait +
+		u.GapSoftIRQ +
+		u.GapSteal
+	if guestCorrection {
+		busy += u.GapGuest
+	}
+	total := u.GapUser +
+		u.GapNice +
+		u.GapSystem +
+		u.GapIdle +
+		u.GapIowait +
+		u.GapIRQ +
+		u.GapSoftIRQ +
+		u.GapSteal +
+		u.GapGuest +
+		u.GapGuestNice
+	u.Busy = busy
+	u.Total = total
+	if total > 0 {
+		u.Usage = float64(busy) / float64(total) * 100.0
+	}
+}
+
+// gap returns the increase of a counter. A counter going backwards, such as
+// after cpu hotplug, is treated as no progress rather than wrapping.
+func gap(cur, prev uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
 }
 
 func (w *Worker) Run() {
@@ -122,11 +309,10 @@ func (w *Worker) Run() {
 		// increment idle time
 		atomic.AddInt64(&w.idleTime, 1)
 
-		cpu, err := GetStat()
-		if err != nil {
+		if err := w.tick(time.Now()); err != nil {
 			log.Printf("%v", err)
 			continue
 		}
-		w.calculatingGap(cpu)
+		w.notify()
 	}
 }
@@ -67,7 +67,7 @@ func TestCalculatingGap_SecondCallComputesGapAndUsage(t *testing.T) {
 	// Usage calculation
 	numerator := got.GapUser + got.GapSystem + got.GapIowait + got.GapSoftIRQ + got.GapSteal
 	denominator := got.GapUser + got.GapNice + got.GapSystem + got.GapIdle + got.GapIowait + got.GapIRQ + got.GapSoftIRQ + got.GapSteal + got.GapGuest + got.GapGuestNice
-	expectedUsage := (numerator / denominator) * 100.0
+	expectedUsage := float64(numerator) / float64(denominator) * 100.0
 	if got.Usage != expectedUsage {
 		t.Errorf("Expected Usage=%v, got %v", expectedUsage, got.Usage)
 	}
@@ -79,7 +79,7 @@ func TestCalculatingGap_RingBufferWrapsAround(t *testing.T) {
 	w.calculatingGap(&cpuStat{})
 	// Fill usages[1..historySize-1]
 	for i := 1; i < historySize; i++ {
-		w.calculatingGap(&cpuStat{User: float64(i)})
+		w.calculatingGap(&cpuStat{User: uint64(i)})
 	}
 	// Next call should wrap to usages[1]
 	w.calculatingGap(&cpuStat{User: 999})
@@ -87,3 +87,75 @@ func TestCalculatingGap_RingBufferWrapsAround(t *testing.T) {
 		t.Errorf("Expected usages[1] to be overwritten with User=999, got %+v", w.usages[1])
 	}
 }
+
+func TestCalculatingGap_CounterGoingBackwards(t *testing.T) {
+	w := New()
+	w.calculatingGap(&cpuStat{User: 100, Idle: 100})
+	w.calculatingGap(&cpuStat{User: 50, Idle: 200})
+	got := w.usages[1]
+	if got.GapUser != 0 || got.GapIdle != 100 {
+		t.Errorf("Unexpected gap values: %+v", got)
+	}
+	if got.Usage != 0 {
+		t.Errorf("Expected Usage=0, got %v", got.Usage)
+	}
+}
+
+func TestCalculatingGap_NoProgress(t *testing.T) {
+	w := New()
+	w.calculatingGap(&cpuStat{User: 100, Idle: 100})
+	w.calculatingGap(&cpuStat{User: 100, Idle: 100})
+	if got := w.usages[1].Usage; got != 0 {
+		t.Errorf("Expected Usage=0 without progress, got %v", got)
+	}
+}
+
+func TestCalculatingGap_GuestCorrection(t *testing.T) {
+	first := &cpuStat{}
+	// user and nice include guest and guest_nice
+	second := &cpuStat{User: 60, Nice: 10, System: 10, Idle: 20, Guest: 40, GuestNice: 10}
+
+	w := New()
+	w.calculatingGap(first)
+	w.calculatingGap(second)
+	// 70 / 150 without correction
+	if want := 70.0 / 150.0 * 100.0; w.usages[1].Usage != want {
+		t.Errorf("Expected Usage=%v without correction, got %v", want, w.usages[1].Usage)
+	}
+
+	w = newWorker(Config{GuestCorrection: true})
+	w.calculatingGap(first)
+	w.calculatingGap(second)
+	got := w.usages[1]
+	if got.GapUser != 20 || got.GapNice != 0 || got.GapGuest != 40 || got.GapGuestNice != 10 {
+		t.Errorf("Unexpected corrected gap values: %+v", got)
+	}
+	// (20 + 10 + 40) / 100
+	if got.Usage != 70 {
+		t.Errorf("Expected Usage=70 with correction, got %v", got.Usage)
+	}
+}
+
+func TestCalculatingGap_PerCPU(t *testing.T) {
+	w := New()
+	w.calculatingGap(&cpuStat{User: 100, Idle: 100, CPUs: map[int]*cpuStat{
+		0: {User: 50, Idle: 50},
+		1: {User: 50, Idle: 50},
+	}})
+	w.calculatingGap(&cpuStat{User: 200, Idle: 200, CPUs: map[int]*cpuStat{
+		0: {User: 125, Idle: 75},
+		1: {User: 75, Idle: 125},
+		2: {User: 10, Idle: 10},
+	}})
+	got := w.usages[1]
+	if got.Usage != 50 {
+		t.Errorf("Expected Usage=50, got %v", got.Usage)
+	}
+	if got.CPUs[0].Usage != 75 || got.CPUs[1].Usage != 25 {
+		t.Errorf("Unexpected per-cpu usage: %v %v", got.CPUs[0].Usage, got.CPUs[1].Usage)
+	}
+	// cpu2 came online, no gap yet
+	if got.CPUs[2].Total != 0 {
+		t.Errorf("Expected no gap for new cpu, got %+v", got.CPUs[2])
+	}
+}
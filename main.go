@@ -11,12 +11,14 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"syscall"
 	"time"
 
 	connect "github.com/bufbuild/connect-go"
 	"github.com/jessevdk/go-flags"
 	"github.com/monitoring-forge/mackerel-plugin-maxcpu/internal/statworker"
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
 	maxcpuconnect "github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu/maxcpuconnect"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -28,17 +30,147 @@ type Opt struct {
 	Socket   string `short:"s" long:"socket" required:"true" description:"Socket file used calcurating daemon" `
 	AsDaemon bool   `long:"as-daemon" description:"run as daemon"`
 	Version  bool   `short:"v" long:"version" description:"Show version"`
-	client   maxcpuconnect.MaxCPUClient
+	// client options
+	Consumer      string `long:"consumer" description:"Name of the reader. Each consumer gets the stats since its own previous read. Defaults to check-steal with --check-steal"`
+	Peek          bool   `long:"peek" description:"Show the stats without advancing the read position of the consumer"`
+	Window        int32  `long:"window" value-name:"SECONDS" description:"Show the stats of the last SECONDS regardless of the read position"`
+	StateFile     string `long:"state-file" value-name:"FILE" description:"File to keep the position of the stats processed. A retry after a failure gets the same stats again"`
+	Samples       bool   `long:"samples" description:"Show the per second samples kept in the daemon"`
+	From          int64  `long:"from" value-name:"EPOCH" description:"Show the samples taken at or after EPOCH with --samples"`
+	To            int64  `long:"to" value-name:"EPOCH" description:"Show the samples taken at or before EPOCH with --samples"`
+	Watch         bool   `long:"watch" description:"Show each sample as it is taken until interrupted"`
+	WatchInterval int32  `long:"watch-interval" value-name:"SECONDS" description:"Show the stats of every SECONDS instead of each sample with --watch"`
+	// daemon options
+	GuestCorrection   string   `long:"guest-correction" default:"auto" choice:"auto" choice:"on" choice:"off" description:"Subtract guest time from user/nice. auto enables it on KVM hypervisors"`
+	PhysicalCores     bool     `long:"physical-cores" description:"Report peak usage per physical core and socket, and saturated physical cores"`
+	NUMA              bool     `long:"numa" description:"Report usage per NUMA node"`
+	CoreSkew          bool     `long:"core-skew" description:"Report how unevenly the load is spread over the cpus"`
+	CPUGroups         []string `long:"cpu-group" value-name:"NAME=SPEC" description:"Report usage of a named cpu group. SPEC is a cpu list like 0-1, isolated or cgroup:PATH. Can be repeated"`
+	SoftIRQs          bool     `long:"softirqs" description:"Report peak rates of each softirq type and the dominant type at the peak of cpu usage"`
+	SoftIRQsPerCPU    bool     `long:"softirqs-per-cpu" description:"Report peak rates of each softirq type per cpu. Implies --softirqs"`
+	Interrupts        bool     `long:"interrupts" description:"Report the busiest IRQs at the peak of cpu usage and the share of IRQs on the busiest cpu"`
+	Schedstat         bool     `long:"schedstat" description:"Report time tasks waited on the run queue from /proc/schedstat"`
+	SchedstatPerCPU   bool     `long:"schedstat-per-cpu" description:"Report time tasks waited on the run queue per cpu. Implies --schedstat"`
+	CPUIdle           bool     `long:"cpuidle" description:"Report residency of the cpu idle states"`
+	CPUFreq           bool     `long:"cpufreq" description:"Report cpu frequency and thermal throttle events"`
+	FreqWeightedUsage bool     `long:"freq-weighted-usage" description:"Report usage weighted by the ratio of current to max cpu frequency. Implies --cpufreq"`
+	RAPL              bool     `long:"rapl" description:"Report package power in watts from RAPL powercap"`
+	Thermal           bool     `long:"thermal" description:"Report temperature of the thermal zones"`
+	Steal             bool     `long:"steal" description:"Report max steal, steal share of busy time at the peak of cpu usage and seconds with high steal"`
+	StealThreshold    float64  `long:"steal-threshold" default:"10" description:"Steal percent counted as high steal"`
+	Diskstats         bool     `long:"diskstats" description:"Report peak utilization and IOPS of the block devices, and the busiest device at the peak of cpu usage"`
+	NetDev            bool     `long:"netdev" description:"Report peak bytes and packets per second of the network interfaces"`
+	Config            string   `long:"config" value-name:"FILE" description:"Config file of additional sources such as gauge and counter files"`
+	// check options
+	CheckSteal    bool `long:"check-steal" description:"Run as a check plugin for steal time. Implies --steal"`
+	StealWarning  int  `long:"steal-warning" default:"30" description:"Seconds with high steal to be warning in --check-steal"`
+	StealCritical int  `long:"steal-critical" default:"60" description:"Seconds with high steal to be critical in --check-steal"`
+	client        maxcpuconnect.MaxCPUClient
 }
 
-func runBinaryCheck(socket string, current time.Time) {
+// daemonArgs returns the arguments to spawn the calculating daemon with the same options
+func daemonArgs(opt *Opt) []string {
+	args := []string{
+		"--as-daemon",
+		"--socket", opt.Socket,
+		"--guest-correction", opt.GuestCorrection,
+	}
+	if opt.PhysicalCores {
+		args = append(args, "--physical-cores")
+	}
+	if opt.NUMA {
+		args = append(args, "--numa")
+	}
+	if opt.CoreSkew {
+		args = append(args, "--core-skew")
+	}
+	for _, g := range opt.CPUGroups {
+		args = append(args, "--cpu-group", g)
+	}
+	if opt.SoftIRQs {
+		args = append(args, "--softirqs")
+	}
+	if opt.SoftIRQsPerCPU {
+		args = append(args, "--softirqs-per-cpu")
+	}
+	if opt.Interrupts {
+		args = append(args, "--interrupts")
+	}
+	if opt.Schedstat {
+		args = append(args, "--schedstat")
+	}
+	if opt.SchedstatPerCPU {
+		args = append(args, "--schedstat-per-cpu")
+	}
+	if opt.CPUIdle {
+		args = append(args, "--cpuidle")
+	}
+	if opt.CPUFreq {
+		args = append(args, "--cpufreq")
+	}
+	if opt.FreqWeightedUsage {
+		args = append(args, "--freq-weighted-usage")
+	}
+	if opt.RAPL {
+		args = append(args, "--rapl")
+	}
+	if opt.Thermal {
+		args = append(args, "--thermal")
+	}
+	if opt.Steal {
+		args = append(args, "--steal", "--steal-threshold", strconv.FormatFloat(opt.StealThreshold, 'f', -1, 64))
+	}
+	if opt.Diskstats {
+		args = append(args, "--diskstats")
+	}
+	if opt.NetDev {
+		args = append(args, "--netdev")
+	}
+	if opt.Config != "" {
+		args = append(args, "--config", opt.Config)
+	}
+	return args
+}
+
+func workerConfig(opt *Opt) statworker.Config {
+	cfg := statworker.Config{
+		PhysicalCores:     opt.PhysicalCores,
+		NUMA:              opt.NUMA,
+		CoreSkew:          opt.CoreSkew,
+		CPUGroups:         opt.CPUGroups,
+		SoftIRQs:          opt.SoftIRQs,
+		SoftIRQsPerCPU:    opt.SoftIRQsPerCPU,
+		Interrupts:        opt.Interrupts,
+		Schedstat:         opt.Schedstat,
+		SchedstatPerCPU:   opt.SchedstatPerCPU,
+		CPUIdle:           opt.CPUIdle,
+		CPUFreq:           opt.CPUFreq,
+		FreqWeightedUsage: opt.FreqWeightedUsage,
+		RAPL:              opt.RAPL,
+		Thermal:           opt.Thermal,
+		Steal:             opt.Steal,
+		StealThreshold:    opt.StealThreshold,
+		Diskstats:         opt.Diskstats,
+		NetDev:            opt.NetDev,
+		SourcesConfig:     opt.Config,
+	}
+	switch opt.GuestCorrection {
+	case "on":
+		cfg.GuestCorrection = true
+	case "auto":
+		cfg.GuestCorrection = statworker.IsHypervisor()
+	}
+	return cfg
+}
+
+func runBinaryCheck(opt *Opt, current time.Time) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 	for range ticker.C {
 		modified, err := selfModified()
 		if err == nil {
 			if modified != current {
-				cmd := exec.Command(os.Args[0], "--as-daemon", "--socket", socket)
+				cmd := exec.Command(os.Args[0], daemonArgs(opt)...)
 				err = cmd.Start()
 				if err != nil {
 					log.Printf("%v", err)
@@ -68,8 +200,14 @@ func execBackground(opt *Opt) int {
 		log.Printf("%v", err)
 		return 1
 	}
+	// check options before exec, the daemon cannot report errors
+	_, err = statworker.NewWithConfig(workerConfig(opt))
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
 
-	cmd := exec.Command(os.Args[0], "--as-daemon", "--socket", opt.Socket)
+	cmd := exec.Command(os.Args[0], daemonArgs(opt)...)
 	err = cmd.Start()
 	if err != nil {
 		log.Printf("%v", err)
@@ -97,11 +235,15 @@ func runBackground(opt *Opt) int {
 		return 1
 	}
 
-	worker := statworker.New()
+	worker, err := statworker.NewWithConfig(workerConfig(opt))
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
 
 	go func() { worker.Run() }()
 	go func() { runIdleCheck(worker) }()
-	go func() { runBinaryCheck(opt.Socket, modified) }()
+	go func() { runBinaryCheck(opt, modified) }()
 
 	time.Sleep(1 * time.Second)
 
@@ -127,7 +269,15 @@ func runBackground(opt *Opt) int {
 		log.Printf("%v", err)
 		return 1
 	}
-	srv := &http.Server{Handler: mux}
+	// the streams of WatchUsage only end with their context, so the
+	// requests are cancelled at shutdown
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := &http.Server{
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	srv.RegisterOnShutdown(cancelRequests)
 	go func() {
 		if err := srv.Serve(unixListener); err != nil && err != http.ErrServerClosed {
 			log.Printf("%v", err)
@@ -152,25 +302,173 @@ func checkDaemonAlive(opt *Opt) bool {
 	return true
 }
 
+func statsRequest(opt *Opt) *maxcpu.StatsRequest {
+	req := &maxcpu.StatsRequest{
+		Consumer: opt.Consumer,
+		Peek:     opt.Peek,
+		Window:   opt.Window,
+	}
+	if useState(opt) {
+		st, err := readState(opt.StateFile)
+		if err != nil {
+			log.Printf("failed to read state file: %v", err)
+		} else if st != nil && st.Consumer != opt.Consumer {
+			log.Printf("state file is of consumer %q, ignored", st.Consumer)
+		} else if st != nil {
+			req.Ack = st.Seq
+			req.StartedAt = st.StartedAt
+		}
+	}
+	return req
+}
+
 func getStats(opt *Opt) int {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
-	res, err := opt.client.GetStats(ctx, connect.NewRequest(&emptypb.Empty{}))
+	res, err := opt.client.GetStats(ctx, connect.NewRequest(statsRequest(opt)))
 	if err != nil {
 		log.Printf("%v", err)
 		return 1
 	}
-	for _, m := range res.Msg.Metrics {
+	printMetrics(res.Msg.Metrics)
+	saveState(opt, res.Msg)
+	return 0
+}
+
+func printMetrics(metrics []*maxcpu.Metric) {
+	for _, m := range metrics {
+		group := m.Group
+		if group == "" {
+			// daemon of older version
+			group = "us_sy_wa_si_st_usage"
+		}
 		fmt.Printf(
-			"maxcpu.us_sy_wa_si_st_usage.%s\t%f\t%d\n",
+			"maxcpu.%s.%s\t%f\t%d\n",
+			group,
 			m.Key,
 			m.Metric,
 			m.Epoch,
 		)
 	}
+}
+
+// getSamples prints the samples as tab separated values with a header.
+// The times are in seconds.
+func getSamples(opt *Opt) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	res, err := opt.client.GetSamples(ctx, connect.NewRequest(&maxcpu.SamplesRequest{From: opt.From, To: opt.To}))
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	printSampleHeader()
+	for _, s := range res.Msg.Samples {
+		printSample(s)
+	}
+	return 0
+}
+
+func printSampleHeader() {
+	fmt.Println("epoch\tseq\tusage\tuser\tnice\tsystem\tidle\tiowait\tirq\tsoftirq\tsteal\tguest\tguest_nice")
+}
+
+func printSample(s *maxcpu.Sample) {
+	fmt.Printf(
+		"%d\t%d\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\n",
+		s.Epoch,
+		s.Seq,
+		s.Usage,
+		s.User,
+		s.Nice,
+		s.System,
+		s.Idle,
+		s.Iowait,
+		s.IRQ,
+		s.SoftIRQ,
+		s.Steal,
+		s.Guest,
+		s.GuestNice,
+	)
+}
+
+// watchUsage prints each sample, or the stats of every --watch-interval
+// seconds, until interrupted.
+func watchUsage(opt *Opt) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	stream, err := opt.client.WatchUsage(ctx, connect.NewRequest(&maxcpu.WatchRequest{Interval: opt.WatchInterval}))
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer stream.Close()
+	if opt.WatchInterval <= 1 {
+		printSampleHeader()
+	}
+	for stream.Receive() {
+		res := stream.Msg()
+		if res.Sample != nil {
+			printSample(res.Sample)
+		}
+		printMetrics(res.Metrics)
+	}
+	if err := stream.Err(); err != nil && ctx.Err() == nil {
+		log.Printf("%v", err)
+		return 1
+	}
 	return 0
 }
 
+// check plugin exit codes
+const (
+	checkOK = iota
+	checkWarning
+	checkCritical
+	checkUnknown
+)
+
+var checkStatus = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+func printCheck(status int, msg string) int {
+	fmt.Printf("MaxCPU Steal %s: %s\n", checkStatus[status], msg)
+	return status
+}
+
+// checkSteal reports the seconds with high steal as a check plugin
+func checkSteal(opt *Opt) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	res, err := opt.client.GetStats(ctx, connect.NewRequest(statsRequest(opt)))
+	if err != nil {
+		return printCheck(checkUnknown, err.Error())
+	}
+	var over, peak float64
+	found := false
+	for _, m := range res.Msg.Metrics {
+		switch m.Group + "." + m.Key {
+		case "steal_seconds.over_threshold":
+			over = m.Metric
+			found = true
+		case "steal.max_usage":
+			peak = m.Metric
+		}
+	}
+	if !found {
+		return printCheck(checkUnknown, "steal metrics not found, restart the daemon with --steal")
+	}
+	saveState(opt, res.Msg)
+	// the daemon counts the seconds with its own threshold
+	msg := fmt.Sprintf("%.0f seconds with steal >= %g%%, max steal %.1f%%", over, res.Msg.StealThreshold, peak)
+	switch {
+	case over >= float64(opt.StealCritical):
+		return printCheck(checkCritical, msg)
+	case over >= float64(opt.StealWarning):
+		return printCheck(checkWarning, msg)
+	}
+	return printCheck(checkOK, msg)
+}
+
 func makeClient(socket string) (maxcpuconnect.MaxCPUClient, error) {
 	uid := os.Geteuid()
 	httpClient := &http.Client{
@@ -230,6 +528,12 @@ func _main() int {
 	if opt.AsDaemon {
 		return runBackground(opt)
 	}
+	if opt.CheckSteal {
+		opt.Steal = true
+		if opt.Consumer == "" {
+			opt.Consumer = "check-steal"
+		}
+	}
 
 	client, err := makeClient(opt.Socket)
 	if err != nil {
@@ -241,8 +545,21 @@ func _main() int {
 	if !checkDaemonAlive(opt) {
 		// exec daemon
 		log.Printf("start background process")
-		return execBackground(opt)
+		ret := execBackground(opt)
+		if opt.CheckSteal && ret == 0 {
+			return printCheck(checkUnknown, "background process started")
+		}
+		return ret
 	}
 
+	if opt.CheckSteal {
+		return checkSteal(opt)
+	}
+	if opt.Samples {
+		return getSamples(opt)
+	}
+	if opt.Watch {
+		return watchUsage(opt)
+	}
 	return getStats(opt)
 }
@@ -2,15 +2,20 @@ package main
 
 import (
 	"context"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"path"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	connect "github.com/bufbuild/connect-go"
+	"github.com/jessevdk/go-flags"
 	"github.com/monitoring-forge/mackerel-plugin-maxcpu/internal/statworker"
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
 	maxcpuconnect "github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu/maxcpuconnect"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -63,3 +68,105 @@ func TestMakeClient(t *testing.T) {
 		t.Errorf("unexpected Hello response: %v", resp.Msg.Message)
 	}
 }
+
+func TestDaemonArgs(t *testing.T) {
+	opt := &Opt{}
+	psr := flags.NewParser(opt, flags.HelpFlag|flags.PassDoubleDash)
+	_, err := psr.ParseArgs([]string{
+		"-s", "/tmp/maxcpu.sock",
+		"--guest-correction", "off",
+		"--physical-cores",
+		"--numa",
+		"--core-skew",
+		"--cpu-group", "a=0",
+		"--cpu-group", "b=1-2",
+		"--softirqs",
+		"--softirqs-per-cpu",
+		"--interrupts",
+		"--schedstat",
+		"--schedstat-per-cpu",
+		"--cpuidle",
+		"--cpufreq",
+		"--freq-weighted-usage",
+		"--rapl",
+		"--thermal",
+		"--steal",
+		"--steal-threshold", "12.5",
+		"--diskstats",
+		"--netdev",
+		"--config", "/etc/maxcpu.conf",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := &Opt{}
+	psr = flags.NewParser(daemon, flags.HelpFlag|flags.PassDoubleDash)
+	if _, err := psr.ParseArgs(daemonArgs(opt)); err != nil {
+		t.Fatal(err)
+	}
+	if !daemon.AsDaemon {
+		t.Error("expected --as-daemon")
+	}
+	// the daemon gets the same options
+	daemon.AsDaemon = false
+	if !reflect.DeepEqual(opt, daemon) {
+		t.Errorf("unexpected daemon options: %+v, want %+v", daemon, opt)
+	}
+	if workerConfig(daemon).GuestCorrection {
+		t.Error("expected guest correction disabled")
+	}
+}
+
+type stubClient struct {
+	maxcpuconnect.MaxCPUClient
+	metrics   []*maxcpu.Metric
+	threshold float64
+}
+
+func (c *stubClient) GetStats(context.Context, *connect.Request[maxcpu.StatsRequest]) (*connect.Response[maxcpu.StatsResponse], error) {
+	return connect.NewResponse(&maxcpu.StatsResponse{Metrics: c.metrics, StealThreshold: c.threshold}), nil
+}
+
+func TestCheckSteal(t *testing.T) {
+	tests := []struct {
+		metrics []*maxcpu.Metric
+		want    int
+	}{
+		{nil, checkUnknown},
+		{[]*maxcpu.Metric{{Group: "steal_seconds", Key: "over_threshold", Metric: 10}}, checkOK},
+		{[]*maxcpu.Metric{{Group: "steal_seconds", Key: "over_threshold", Metric: 30}}, checkWarning},
+		{[]*maxcpu.Metric{{Group: "steal_seconds", Key: "over_threshold", Metric: 60}}, checkCritical},
+	}
+	for _, tt := range tests {
+		opt := &Opt{StealThreshold: 10, StealWarning: 30, StealCritical: 60}
+		opt.client = &stubClient{metrics: tt.metrics}
+		if got := checkSteal(opt); got != tt.want {
+			t.Errorf("%v: expected %d, got %d", tt.metrics, tt.want, got)
+		}
+	}
+}
+
+func TestCheckSteal_DaemonThreshold(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	opt := &Opt{StealThreshold: 10, StealWarning: 30, StealCritical: 60}
+	opt.client = &stubClient{
+		metrics:   []*maxcpu.Metric{{Group: "steal_seconds", Key: "over_threshold", Metric: 10}},
+		threshold: 5,
+	}
+	checkSteal(opt)
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "steal >= 5%") {
+		t.Errorf("expected the threshold of the daemon, got %q", out)
+	}
+}
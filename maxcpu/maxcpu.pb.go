@@ -66,16 +66,111 @@ func (x *HelloResponse) GetMessage() string {
 	return ""
 }
 
-type StatsResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Metrics       []*Metric              `protobuf:"bytes,1,rep,name=Metrics,proto3" json:"Metrics,omitempty"`
+type StatsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Consumer names the reader. Each consumer gets the stats since its own
+	// previous read. Empty is the default consumer.
+	Consumer string `protobuf:"bytes,1,opt,name=Consumer,proto3" json:"Consumer,omitempty"`
+	// Peek returns the stats without advancing the read position of the
+	// consumer.
+	Peek bool `protobuf:"varint,2,opt,name=Peek,proto3" json:"Peek,omitempty"`
+	// Window is the number of seconds to get the stats of the latest
+	// samples, regardless of the read position. Consumer and Peek are
+	// ignored when set.
+	Window int32 `protobuf:"varint,3,opt,name=Window,proto3" json:"Window,omitempty"`
+	// Ack is the Seq of the last response the consumer has processed. The
+	// stats are of the samples after it, so that a retry after a failure
+	// gets the same samples again. It is ignored when StartedAt does not
+	// match the daemon, such as after the daemon restarted.
+	Ack uint64 `protobuf:"varint,4,opt,name=Ack,proto3" json:"Ack,omitempty"`
+	// StartedAt is the StartedAt of the response Ack was given by.
+	StartedAt     int64 `protobuf:"varint,5,opt,name=StartedAt,proto3" json:"StartedAt,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *StatsRequest) Reset() {
+	*x = StatsRequest{}
+	mi := &file_maxcpu_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsRequest) ProtoMessage() {}
+
+func (x *StatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_maxcpu_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return file_maxcpu_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StatsRequest) GetConsumer() string {
+	if x != nil {
+		return x.Consumer
+	}
+	return ""
+}
+
+func (x *StatsRequest) GetPeek() bool {
+	if x != nil {
+		return x.Peek
+	}
+	return false
+}
+
+func (x *StatsRequest) GetWindow() int32 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+func (x *StatsRequest) GetAck() uint64 {
+	if x != nil {
+		return x.Ack
+	}
+	return 0
+}
+
+func (x *StatsRequest) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+type StatsResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Metrics []*Metric              `protobuf:"bytes,1,rep,name=Metrics,proto3" json:"Metrics,omitempty"`
+	// Seq is the sequence number of the latest sample in the stats.
+	Seq uint64 `protobuf:"varint,2,opt,name=Seq,proto3" json:"Seq,omitempty"`
+	// StartedAt identifies the daemon the sequence numbers belong to.
+	StartedAt int64 `protobuf:"varint,3,opt,name=StartedAt,proto3" json:"StartedAt,omitempty"`
+	// StealThreshold is the steal percent the daemon counts in
+	// steal_seconds, 0 without the steal stats.
+	StealThreshold float64 `protobuf:"fixed64,4,opt,name=StealThreshold,proto3" json:"StealThreshold,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
 func (x *StatsResponse) Reset() {
 	*x = StatsResponse{}
-	mi := &file_maxcpu_proto_msgTypes[1]
+	mi := &file_maxcpu_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -87,7 +182,7 @@ func (x *StatsResponse) String() string {
 func (*StatsResponse) ProtoMessage() {}
 
 func (x *StatsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_maxcpu_proto_msgTypes[1]
+	mi := &file_maxcpu_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -100,7 +195,7 @@ func (x *StatsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatsResponse.ProtoReflect.Descriptor instead.
 func (*StatsResponse) Descriptor() ([]byte, []int) {
-	return file_maxcpu_proto_rawDescGZIP(), []int{1}
+	return file_maxcpu_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *StatsResponse) GetMetrics() []*Metric {
@@ -110,18 +205,41 @@ func (x *StatsResponse) GetMetrics() []*Metric {
 	return nil
 }
 
+func (x *StatsResponse) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetStealThreshold() float64 {
+	if x != nil {
+		return x.StealThreshold
+	}
+	return 0
+}
+
 type Metric struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
-	Metric        float64                `protobuf:"fixed64,2,opt,name=Metric,proto3" json:"Metric,omitempty"`
-	Epoch         int64                  `protobuf:"varint,3,opt,name=Epoch,proto3" json:"Epoch,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Key    string                 `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Metric float64                `protobuf:"fixed64,2,opt,name=Metric,proto3" json:"Metric,omitempty"`
+	Epoch  int64                  `protobuf:"varint,3,opt,name=Epoch,proto3" json:"Epoch,omitempty"`
+	// Group is the graph name of the metric. Empty means us_sy_wa_si_st_usage.
+	Group         string `protobuf:"bytes,4,opt,name=Group,proto3" json:"Group,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Metric) Reset() {
 	*x = Metric{}
-	mi := &file_maxcpu_proto_msgTypes[2]
+	mi := &file_maxcpu_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -133,7 +251,7 @@ func (x *Metric) String() string {
 func (*Metric) ProtoMessage() {}
 
 func (x *Metric) ProtoReflect() protoreflect.Message {
-	mi := &file_maxcpu_proto_msgTypes[2]
+	mi := &file_maxcpu_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -146,7 +264,7 @@ func (x *Metric) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Metric.ProtoReflect.Descriptor instead.
 func (*Metric) Descriptor() ([]byte, []int) {
-	return file_maxcpu_proto_rawDescGZIP(), []int{2}
+	return file_maxcpu_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Metric) GetKey() string {
@@ -170,22 +288,408 @@ func (x *Metric) GetEpoch() int64 {
 	return 0
 }
 
+func (x *Metric) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+type SamplesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// From and To limit the samples to the unix time range, inclusive.
+	// Zero means unbounded.
+	From          int64 `protobuf:"varint,1,opt,name=From,proto3" json:"From,omitempty"`
+	To            int64 `protobuf:"varint,2,opt,name=To,proto3" json:"To,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SamplesRequest) Reset() {
+	*x = SamplesRequest{}
+	mi := &file_maxcpu_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SamplesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SamplesRequest) ProtoMessage() {}
+
+func (x *SamplesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_maxcpu_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SamplesRequest.ProtoReflect.Descriptor instead.
+func (*SamplesRequest) Descriptor() ([]byte, []int) {
+	return file_maxcpu_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SamplesRequest) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *SamplesRequest) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+type SamplesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Samples       []*Sample              `protobuf:"bytes,1,rep,name=Samples,proto3" json:"Samples,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SamplesResponse) Reset() {
+	*x = SamplesResponse{}
+	mi := &file_maxcpu_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SamplesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SamplesResponse) ProtoMessage() {}
+
+func (x *SamplesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_maxcpu_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SamplesResponse.ProtoReflect.Descriptor instead.
+func (*SamplesResponse) Descriptor() ([]byte, []int) {
+	return file_maxcpu_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SamplesResponse) GetSamples() []*Sample {
+	if x != nil {
+		return x.Samples
+	}
+	return nil
+}
+
+// Sample is a per second sample of /proc/stat. The times are the increase
+// of the counters since the previous sample, in seconds.
+type Sample struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           uint64                 `protobuf:"varint,1,opt,name=Seq,proto3" json:"Seq,omitempty"`
+	Epoch         int64                  `protobuf:"varint,2,opt,name=Epoch,proto3" json:"Epoch,omitempty"`
+	Usage         float64                `protobuf:"fixed64,3,opt,name=Usage,proto3" json:"Usage,omitempty"`
+	User          float64                `protobuf:"fixed64,4,opt,name=User,proto3" json:"User,omitempty"`
+	Nice          float64                `protobuf:"fixed64,5,opt,name=Nice,proto3" json:"Nice,omitempty"`
+	System        float64                `protobuf:"fixed64,6,opt,name=System,proto3" json:"System,omitempty"`
+	Idle          float64                `protobuf:"fixed64,7,opt,name=Idle,proto3" json:"Idle,omitempty"`
+	Iowait        float64                `protobuf:"fixed64,8,opt,name=Iowait,proto3" json:"Iowait,omitempty"`
+	IRQ           float64                `protobuf:"fixed64,9,opt,name=IRQ,proto3" json:"IRQ,omitempty"`
+	SoftIRQ       float64                `protobuf:"fixed64,10,opt,name=SoftIRQ,proto3" json:"SoftIRQ,omitempty"`
+	Steal         float64                `protobuf:"fixed64,11,opt,name=Steal,proto3" json:"Steal,omitempty"`
+	Guest         float64                `protobuf:"fixed64,12,opt,name=Guest,proto3" json:"Guest,omitempty"`
+	GuestNice     float64                `protobuf:"fixed64,13,opt,name=GuestNice,proto3" json:"GuestNice,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sample) Reset() {
+	*x = Sample{}
+	mi := &file_maxcpu_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sample) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sample) ProtoMessage() {}
+
+func (x *Sample) ProtoReflect() protoreflect.Message {
+	mi := &file_maxcpu_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sample.ProtoReflect.Descriptor instead.
+func (*Sample) Descriptor() ([]byte, []int) {
+	return file_maxcpu_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Sample) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Sample) GetEpoch() int64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *Sample) GetUsage() float64 {
+	if x != nil {
+		return x.Usage
+	}
+	return 0
+}
+
+func (x *Sample) GetUser() float64 {
+	if x != nil {
+		return x.User
+	}
+	return 0
+}
+
+func (x *Sample) GetNice() float64 {
+	if x != nil {
+		return x.Nice
+	}
+	return 0
+}
+
+func (x *Sample) GetSystem() float64 {
+	if x != nil {
+		return x.System
+	}
+	return 0
+}
+
+func (x *Sample) GetIdle() float64 {
+	if x != nil {
+		return x.Idle
+	}
+	return 0
+}
+
+func (x *Sample) GetIowait() float64 {
+	if x != nil {
+		return x.Iowait
+	}
+	return 0
+}
+
+func (x *Sample) GetIRQ() float64 {
+	if x != nil {
+		return x.IRQ
+	}
+	return 0
+}
+
+func (x *Sample) GetSoftIRQ() float64 {
+	if x != nil {
+		return x.SoftIRQ
+	}
+	return 0
+}
+
+func (x *Sample) GetSteal() float64 {
+	if x != nil {
+		return x.Steal
+	}
+	return 0
+}
+
+func (x *Sample) GetGuest() float64 {
+	if x != nil {
+		return x.Guest
+	}
+	return 0
+}
+
+func (x *Sample) GetGuestNice() float64 {
+	if x != nil {
+		return x.GuestNice
+	}
+	return 0
+}
+
+type WatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Interval is the number of seconds to summarize the samples of. Each
+	// sample is sent when it is 0 or 1.
+	Interval      int32 `protobuf:"varint,1,opt,name=Interval,proto3" json:"Interval,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_maxcpu_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_maxcpu_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_maxcpu_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchRequest) GetInterval() int32 {
+	if x != nil {
+		return x.Interval
+	}
+	return 0
+}
+
+type WatchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sample is set when each sample is sent.
+	Sample *Sample `protobuf:"bytes,1,opt,name=Sample,proto3" json:"Sample,omitempty"`
+	// Metrics are the stats of the samples in the interval.
+	Metrics       []*Metric `protobuf:"bytes,2,rep,name=Metrics,proto3" json:"Metrics,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchResponse) Reset() {
+	*x = WatchResponse{}
+	mi := &file_maxcpu_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchResponse) ProtoMessage() {}
+
+func (x *WatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_maxcpu_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchResponse.ProtoReflect.Descriptor instead.
+func (*WatchResponse) Descriptor() ([]byte, []int) {
+	return file_maxcpu_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WatchResponse) GetSample() *Sample {
+	if x != nil {
+		return x.Sample
+	}
+	return nil
+}
+
+func (x *WatchResponse) GetMetrics() []*Metric {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
 var File_maxcpu_proto protoreflect.FileDescriptor
 
 const file_maxcpu_proto_rawDesc = "" +
 	"\n" +
 	"\fmaxcpu.proto\x12\x06maxcpu\x1a\x1bgoogle/protobuf/empty.proto\")\n" +
 	"\rHelloResponse\x12\x18\n" +
-	"\aMessage\x18\x01 \x01(\tR\aMessage\"9\n" +
+	"\aMessage\x18\x01 \x01(\tR\aMessage\"\x86\x01\n" +
+	"\fStatsRequest\x12\x1a\n" +
+	"\bConsumer\x18\x01 \x01(\tR\bConsumer\x12\x12\n" +
+	"\x04Peek\x18\x02 \x01(\bR\x04Peek\x12\x16\n" +
+	"\x06Window\x18\x03 \x01(\x05R\x06Window\x12\x10\n" +
+	"\x03Ack\x18\x04 \x01(\x04R\x03Ack\x12\x1c\n" +
+	"\tStartedAt\x18\x05 \x01(\x03R\tStartedAt\"\x91\x01\n" +
 	"\rStatsResponse\x12(\n" +
-	"\aMetrics\x18\x01 \x03(\v2\x0e.maxcpu.MetricR\aMetrics\"H\n" +
+	"\aMetrics\x18\x01 \x03(\v2\x0e.maxcpu.MetricR\aMetrics\x12\x10\n" +
+	"\x03Seq\x18\x02 \x01(\x04R\x03Seq\x12\x1c\n" +
+	"\tStartedAt\x18\x03 \x01(\x03R\tStartedAt\x12&\n" +
+	"\x0eStealThreshold\x18\x04 \x01(\x01R\x0eStealThreshold\"^\n" +
 	"\x06Metric\x12\x10\n" +
 	"\x03Key\x18\x01 \x01(\tR\x03Key\x12\x16\n" +
 	"\x06Metric\x18\x02 \x01(\x01R\x06Metric\x12\x14\n" +
-	"\x05Epoch\x18\x03 \x01(\x03R\x05Epoch2\x7f\n" +
-	"\x06MaxCPU\x12;\n" +
-	"\bGetStats\x12\x16.google.protobuf.Empty\x1a\x15.maxcpu.StatsResponse\"\x00\x128\n" +
-	"\x05Hello\x12\x16.google.protobuf.Empty\x1a\x15.maxcpu.HelloResponse\"\x00B;Z9github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpub\x06proto3"
+	"\x05Epoch\x18\x03 \x01(\x03R\x05Epoch\x12\x14\n" +
+	"\x05Group\x18\x04 \x01(\tR\x05Group\"4\n" +
+	"\x0eSamplesRequest\x12\x12\n" +
+	"\x04From\x18\x01 \x01(\x03R\x04From\x12\x0e\n" +
+	"\x02To\x18\x02 \x01(\x03R\x02To\";\n" +
+	"\x0fSamplesResponse\x12(\n" +
+	"\aSamples\x18\x01 \x03(\v2\x0e.maxcpu.SampleR\aSamples\"\xa8\x02\n" +
+	"\x06Sample\x12\x10\n" +
+	"\x03Seq\x18\x01 \x01(\x04R\x03Seq\x12\x14\n" +
+	"\x05Epoch\x18\x02 \x01(\x03R\x05Epoch\x12\x14\n" +
+	"\x05Usage\x18\x03 \x01(\x01R\x05Usage\x12\x12\n" +
+	"\x04User\x18\x04 \x01(\x01R\x04User\x12\x12\n" +
+	"\x04Nice\x18\x05 \x01(\x01R\x04Nice\x12\x16\n" +
+	"\x06System\x18\x06 \x01(\x01R\x06System\x12\x12\n" +
+	"\x04Idle\x18\a \x01(\x01R\x04Idle\x12\x16\n" +
+	"\x06Iowait\x18\b \x01(\x01R\x06Iowait\x12\x10\n" +
+	"\x03IRQ\x18\t \x01(\x01R\x03IRQ\x12\x18\n" +
+	"\aSoftIRQ\x18\n" +
+	" \x01(\x01R\aSoftIRQ\x12\x14\n" +
+	"\x05Steal\x18\v \x01(\x01R\x05Steal\x12\x14\n" +
+	"\x05Guest\x18\f \x01(\x01R\x05Guest\x12\x1c\n" +
+	"\tGuestNice\x18\r \x01(\x01R\tGuestNice\"*\n" +
+	"\fWatchRequest\x12\x1a\n" +
+	"\bInterval\x18\x01 \x01(\x05R\bInterval\"a\n" +
+	"\rWatchResponse\x12&\n" +
+	"\x06Sample\x18\x01 \x01(\v2\x0e.maxcpu.SampleR\x06Sample\x12(\n" +
+	"\aMetrics\x18\x02 \x03(\v2\x0e.maxcpu.MetricR\aMetrics2\xfd\x01\n" +
+	"\x06MaxCPU\x129\n" +
+	"\bGetStats\x12\x14.maxcpu.StatsRequest\x1a\x15.maxcpu.StatsResponse\"\x00\x128\n" +
+	"\x05Hello\x12\x16.google.protobuf.Empty\x1a\x15.maxcpu.HelloResponse\"\x00\x12?\n" +
+	"\n" +
+	"GetSamples\x12\x16.maxcpu.SamplesRequest\x1a\x17.maxcpu.SamplesResponse\"\x00\x12=\n" +
+	"\n" +
+	"WatchUsage\x12\x14.maxcpu.WatchRequest\x1a\x15.maxcpu.WatchResponse\"\x000\x01B;Z9github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpub\x06proto3"
 
 var (
 	file_maxcpu_proto_rawDescOnce sync.Once
@@ -199,24 +703,37 @@ func file_maxcpu_proto_rawDescGZIP() []byte {
 	return file_maxcpu_proto_rawDescData
 }
 
-var file_maxcpu_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_maxcpu_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_maxcpu_proto_goTypes = []any{
-	(*HelloResponse)(nil), // 0: maxcpu.HelloResponse
-	(*StatsResponse)(nil), // 1: maxcpu.StatsResponse
-	(*Metric)(nil),        // 2: maxcpu.Metric
-	(*emptypb.Empty)(nil), // 3: google.protobuf.Empty
+	(*HelloResponse)(nil),   // 0: maxcpu.HelloResponse
+	(*StatsRequest)(nil),    // 1: maxcpu.StatsRequest
+	(*StatsResponse)(nil),   // 2: maxcpu.StatsResponse
+	(*Metric)(nil),          // 3: maxcpu.Metric
+	(*SamplesRequest)(nil),  // 4: maxcpu.SamplesRequest
+	(*SamplesResponse)(nil), // 5: maxcpu.SamplesResponse
+	(*Sample)(nil),          // 6: maxcpu.Sample
+	(*WatchRequest)(nil),    // 7: maxcpu.WatchRequest
+	(*WatchResponse)(nil),   // 8: maxcpu.WatchResponse
+	(*emptypb.Empty)(nil),   // 9: google.protobuf.Empty
 }
 var file_maxcpu_proto_depIdxs = []int32{
-	2, // 0: maxcpu.StatsResponse.Metrics:type_name -> maxcpu.Metric
-	3, // 1: maxcpu.MaxCPU.GetStats:input_type -> google.protobuf.Empty
-	3, // 2: maxcpu.MaxCPU.Hello:input_type -> google.protobuf.Empty
-	1, // 3: maxcpu.MaxCPU.GetStats:output_type -> maxcpu.StatsResponse
-	0, // 4: maxcpu.MaxCPU.Hello:output_type -> maxcpu.HelloResponse
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	3, // 0: maxcpu.StatsResponse.Metrics:type_name -> maxcpu.Metric
+	6, // 1: maxcpu.SamplesResponse.Samples:type_name -> maxcpu.Sample
+	6, // 2: maxcpu.WatchResponse.Sample:type_name -> maxcpu.Sample
+	3, // 3: maxcpu.WatchResponse.Metrics:type_name -> maxcpu.Metric
+	1, // 4: maxcpu.MaxCPU.GetStats:input_type -> maxcpu.StatsRequest
+	9, // 5: maxcpu.MaxCPU.Hello:input_type -> google.protobuf.Empty
+	4, // 6: maxcpu.MaxCPU.GetSamples:input_type -> maxcpu.SamplesRequest
+	7, // 7: maxcpu.MaxCPU.WatchUsage:input_type -> maxcpu.WatchRequest
+	2, // 8: maxcpu.MaxCPU.GetStats:output_type -> maxcpu.StatsResponse
+	0, // 9: maxcpu.MaxCPU.Hello:output_type -> maxcpu.HelloResponse
+	5, // 10: maxcpu.MaxCPU.GetSamples:output_type -> maxcpu.SamplesResponse
+	8, // 11: maxcpu.MaxCPU.WatchUsage:output_type -> maxcpu.WatchResponse
+	8, // [8:12] is the sub-list for method output_type
+	4, // [4:8] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_maxcpu_proto_init() }
@@ -230,7 +747,7 @@ func file_maxcpu_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_maxcpu_proto_rawDesc), len(file_maxcpu_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -38,12 +38,18 @@ const (
 	MaxCPUGetStatsProcedure = "/maxcpu.MaxCPU/GetStats"
 	// MaxCPUHelloProcedure is the fully-qualified name of the MaxCPU's Hello RPC.
 	MaxCPUHelloProcedure = "/maxcpu.MaxCPU/Hello"
+	// MaxCPUGetSamplesProcedure is the fully-qualified name of the MaxCPU's GetSamples RPC.
+	MaxCPUGetSamplesProcedure = "/maxcpu.MaxCPU/GetSamples"
+	// MaxCPUWatchUsageProcedure is the fully-qualified name of the MaxCPU's WatchUsage RPC.
+	MaxCPUWatchUsageProcedure = "/maxcpu.MaxCPU/WatchUsage"
 )
 
 // MaxCPUClient is a client for the maxcpu.MaxCPU service.
 type MaxCPUClient interface {
-	GetStats(context.Context, *connect_go.Request[emptypb.Empty]) (*connect_go.Response[maxcpu.StatsResponse], error)
+	GetStats(context.Context, *connect_go.Request[maxcpu.StatsRequest]) (*connect_go.Response[maxcpu.StatsResponse], error)
 	Hello(context.Context, *connect_go.Request[emptypb.Empty]) (*connect_go.Response[maxcpu.HelloResponse], error)
+	GetSamples(context.Context, *connect_go.Request[maxcpu.SamplesRequest]) (*connect_go.Response[maxcpu.SamplesResponse], error)
+	WatchUsage(context.Context, *connect_go.Request[maxcpu.WatchRequest]) (*connect_go.ServerStreamForClient[maxcpu.WatchResponse], error)
 }
 
 // NewMaxCPUClient constructs a client for the maxcpu.MaxCPU service. By default, it uses the
@@ -56,7 +62,7 @@ type MaxCPUClient interface {
 func NewMaxCPUClient(httpClient connect_go.HTTPClient, baseURL string, opts ...connect_go.ClientOption) MaxCPUClient {
 	baseURL = strings.TrimRight(baseURL, "/")
 	return &maxCPUClient{
-		getStats: connect_go.NewClient[emptypb.Empty, maxcpu.StatsResponse](
+		getStats: connect_go.NewClient[maxcpu.StatsRequest, maxcpu.StatsResponse](
 			httpClient,
 			baseURL+MaxCPUGetStatsProcedure,
 			opts...,
@@ -66,17 +72,29 @@ func NewMaxCPUClient(httpClient connect_go.HTTPClient, baseURL string, opts ...c
 			baseURL+MaxCPUHelloProcedure,
 			opts...,
 		),
+		getSamples: connect_go.NewClient[maxcpu.SamplesRequest, maxcpu.SamplesResponse](
+			httpClient,
+			baseURL+MaxCPUGetSamplesProcedure,
+			opts...,
+		),
+		watchUsage: connect_go.NewClient[maxcpu.WatchRequest, maxcpu.WatchResponse](
+			httpClient,
+			baseURL+MaxCPUWatchUsageProcedure,
+			opts...,
+		),
 	}
 }
 
 // maxCPUClient implements MaxCPUClient.
 type maxCPUClient struct {
-	getStats *connect_go.Client[emptypb.Empty, maxcpu.StatsResponse]
-	hello    *connect_go.Client[emptypb.Empty, maxcpu.HelloResponse]
+	getStats   *connect_go.Client[maxcpu.StatsRequest, maxcpu.StatsResponse]
+	hello      *connect_go.Client[emptypb.Empty, maxcpu.HelloResponse]
+	getSamples *connect_go.Client[maxcpu.SamplesRequest, maxcpu.SamplesResponse]
+	watchUsage *connect_go.Client[maxcpu.WatchRequest, maxcpu.WatchResponse]
 }
 
 // GetStats calls maxcpu.MaxCPU.GetStats.
-func (c *maxCPUClient) GetStats(ctx context.Context, req *connect_go.Request[emptypb.Empty]) (*connect_go.Response[maxcpu.StatsResponse], error) {
+func (c *maxCPUClient) GetStats(ctx context.Context, req *connect_go.Request[maxcpu.StatsRequest]) (*connect_go.Response[maxcpu.StatsResponse], error) {
 	return c.getStats.CallUnary(ctx, req)
 }
 
@@ -85,10 +103,22 @@ func (c *maxCPUClient) Hello(ctx context.Context, req *connect_go.Request[emptyp
 	return c.hello.CallUnary(ctx, req)
 }
 
+// GetSamples calls maxcpu.MaxCPU.GetSamples.
+func (c *maxCPUClient) GetSamples(ctx context.Context, req *connect_go.Request[maxcpu.SamplesRequest]) (*connect_go.Response[maxcpu.SamplesResponse], error) {
+	return c.getSamples.CallUnary(ctx, req)
+}
+
+// WatchUsage calls maxcpu.MaxCPU.WatchUsage.
+func (c *maxCPUClient) WatchUsage(ctx context.Context, req *connect_go.Request[maxcpu.WatchRequest]) (*connect_go.ServerStreamForClient[maxcpu.WatchResponse], error) {
+	return c.watchUsage.CallServerStream(ctx, req)
+}
+
 // MaxCPUHandler is an implementation of the maxcpu.MaxCPU service.
 type MaxCPUHandler interface {
-	GetStats(context.Context, *connect_go.Request[emptypb.Empty]) (*connect_go.Response[maxcpu.StatsResponse], error)
+	GetStats(context.Context, *connect_go.Request[maxcpu.StatsRequest]) (*connect_go.Response[maxcpu.StatsResponse], error)
 	Hello(context.Context, *connect_go.Request[emptypb.Empty]) (*connect_go.Response[maxcpu.HelloResponse], error)
+	GetSamples(context.Context, *connect_go.Request[maxcpu.SamplesRequest]) (*connect_go.Response[maxcpu.SamplesResponse], error)
+	WatchUsage(context.Context, *connect_go.Request[maxcpu.WatchRequest], *connect_go.ServerStream[maxcpu.WatchResponse]) error
 }
 
 // NewMaxCPUHandler builds an HTTP handler from the service implementation. It returns the path on
@@ -107,12 +137,26 @@ func NewMaxCPUHandler(svc MaxCPUHandler, opts ...connect_go.HandlerOption) (stri
 		svc.Hello,
 		opts...,
 	)
+	maxCPUGetSamplesHandler := connect_go.NewUnaryHandler(
+		MaxCPUGetSamplesProcedure,
+		svc.GetSamples,
+		opts...,
+	)
+	maxCPUWatchUsageHandler := connect_go.NewServerStreamHandler(
+		MaxCPUWatchUsageProcedure,
+		svc.WatchUsage,
+		opts...,
+	)
 	return "/maxcpu.MaxCPU/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case MaxCPUGetStatsProcedure:
 			maxCPUGetStatsHandler.ServeHTTP(w, r)
 		case MaxCPUHelloProcedure:
 			maxCPUHelloHandler.ServeHTTP(w, r)
+		case MaxCPUGetSamplesProcedure:
+			maxCPUGetSamplesHandler.ServeHTTP(w, r)
+		case MaxCPUWatchUsageProcedure:
+			maxCPUWatchUsageHandler.ServeHTTP(w, r)
 		default:
 			http.NotFound(w, r)
 		}
@@ -122,10 +166,18 @@ func NewMaxCPUHandler(svc MaxCPUHandler, opts ...connect_go.HandlerOption) (stri
 // UnimplementedMaxCPUHandler returns CodeUnimplemented from all methods.
 type UnimplementedMaxCPUHandler struct{}
 
-func (UnimplementedMaxCPUHandler) GetStats(context.Context, *connect_go.Request[emptypb.Empty]) (*connect_go.Response[maxcpu.StatsResponse], error) {
+func (UnimplementedMaxCPUHandler) GetStats(context.Context, *connect_go.Request[maxcpu.StatsRequest]) (*connect_go.Response[maxcpu.StatsResponse], error) {
 	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("maxcpu.MaxCPU.GetStats is not implemented"))
 }
 
 func (UnimplementedMaxCPUHandler) Hello(context.Context, *connect_go.Request[emptypb.Empty]) (*connect_go.Response[maxcpu.HelloResponse], error) {
 	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("maxcpu.MaxCPU.Hello is not implemented"))
 }
+
+func (UnimplementedMaxCPUHandler) GetSamples(context.Context, *connect_go.Request[maxcpu.SamplesRequest]) (*connect_go.Response[maxcpu.SamplesResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("maxcpu.MaxCPU.GetSamples is not implemented"))
+}
+
+func (UnimplementedMaxCPUHandler) WatchUsage(context.Context, *connect_go.Request[maxcpu.WatchRequest], *connect_go.ServerStream[maxcpu.WatchResponse]) error {
+	return connect_go.NewError(connect_go.CodeUnimplemented, errors.New("maxcpu.MaxCPU.WatchUsage is not implemented"))
+}
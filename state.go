@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+// ackState is the position of the stats the consumer has processed, kept in
+// the state file to be acknowledged at the next read. The state of another
+// consumer is not acknowledged, since it would move the read position of
+// this consumer to the one of the other.
+type ackState struct {
+	Consumer  string `json:"consumer"`
+	StartedAt int64  `json:"started_at"`
+	Seq       uint64 `json:"seq"`
+}
+
+// useState reports whether the read acknowledges the state file. Peeks and
+// windows do not move the read position.
+func useState(opt *Opt) bool {
+	return opt.StateFile != "" && !opt.Peek && opt.Window == 0
+}
+
+// readState reads the state file. It returns nil when the file does not
+// exist yet.
+func readState(path string) (*ackState, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &ackState{}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// writeState replaces the state file, so that it is never left partially
+// written.
+func writeState(path string, st *ackState) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// saveState records the response as processed.
+func saveState(opt *Opt, res *maxcpu.StatsResponse) {
+	if !useState(opt) || res.StartedAt == 0 {
+		return
+	}
+	if err := writeState(opt.StateFile, &ackState{Consumer: opt.Consumer, StartedAt: res.StartedAt, Seq: res.Seq}); err != nil {
+		log.Printf("failed to write state file: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monitoring-forge/mackerel-plugin-maxcpu/maxcpu"
+)
+
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maxcpu.state")
+	st, err := readState(path)
+	if err != nil || st != nil {
+		t.Fatalf("expected nil without state file, got %v %v", st, err)
+	}
+
+	opt := &Opt{StateFile: path}
+	if req := statsRequest(opt); req.Ack != 0 || req.StartedAt != 0 {
+		t.Errorf("expected no ack without state file, got %v", req)
+	}
+	saveState(opt, &maxcpu.StatsResponse{Seq: 42, StartedAt: 1000})
+	req := statsRequest(opt)
+	if req.Ack != 42 || req.StartedAt != 1000 {
+		t.Errorf("expected the saved ack, got %v", req)
+	}
+
+	// peeks and windows do not use the state
+	opt.Peek = true
+	if req := statsRequest(opt); req.Ack != 0 {
+		t.Errorf("expected no ack for peek, got %v", req)
+	}
+	saveState(opt, &maxcpu.StatsResponse{Seq: 50, StartedAt: 1000})
+	if st, err := readState(path); err != nil || st.Seq != 42 {
+		t.Errorf("expected the state to be kept by peek, got %v %v", st, err)
+	}
+	opt.Peek = false
+
+	// the state of another consumer is not acknowledged
+	other := &Opt{StateFile: path, Consumer: "check-steal"}
+	if req := statsRequest(other); req.Ack != 0 || req.StartedAt != 0 {
+		t.Errorf("expected no ack of another consumer, got %v", req)
+	}
+	saveState(other, &maxcpu.StatsResponse{Seq: 60, StartedAt: 1000})
+	if req := statsRequest(other); req.Ack != 60 {
+		t.Errorf("expected the saved ack of the consumer, got %v", req)
+	}
+	if req := statsRequest(opt); req.Ack != 0 {
+		t.Errorf("expected no ack after another consumer saved, got %v", req)
+	}
+
+	if err := os.WriteFile(path, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readState(path); err == nil {
+		t.Error("expected error for broken state file")
+	}
+	if req := statsRequest(opt); req.Ack != 0 {
+		t.Errorf("expected no ack with broken state file, got %v", req)
+	}
+}